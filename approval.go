@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Unattended prod runs - cron, systemd, daemon - require an approval.
+// After reviewing the preview, the operator approves a task:
+//
+//	go-massmail approve -project=fmt -task=reminder [-wave=2026-07]
+//
+// The approval is bound to the wave and a digest of the rendered templates
+// and the attachments; any later change invalidates it.
+// It is not bound to the due time - interval fires, blackout shifts,
+// daemon and catch-up runs of the wave are covered by one approval.
+// It is stored in approvals/[project]-[task]-[wave].json.
+// If env MASSMAIL_APPROVAL_KEY is set, the approval carries an HMAC token,
+// which is verified at runtime; the token may be passed instead of the file:
+//
+//	go-massmail run -mode=prod -unattended -approval=[token]
+
+const approvalKeyEnv = "MASSMAIL_APPROVAL_KEY"
+
+var approvalDir = filepath.Join(".", "approvals")
+
+// approvalT is stored as JSON
+type approvalT struct {
+	Project    string    `json:"project"`
+	Task       string    `json:"task"`
+	Wave       string    `json:"wave"`
+	Digest     string    `json:"digest"`
+	ApprovedBy string    `json:"approved_by"`
+	ApprovedAt time.Time `json:"approved_at"`
+	Token      string    `json:"token,omitempty"`
+}
+
+func approvalFile(project, task, wave string) string {
+	return filepath.Join(approvalDir, fmt.Sprintf("%v-%v-%v.json", project, task, wave))
+}
+
+// attachmentPath - below attachment_root
+func attachmentPath(project string, att AttachmentT) string {
+	if cfg.AttachmentRoot != "" {
+		return filepath.Join(cfg.AttachmentRoot, project, att.Filename)
+	}
+	return filepath.Join(".", "attachments", project, att.Filename)
+}
+
+// approvalDigest hashes the templates of each variant and language -
+// rendered for a synthetic recipient - and the attachment files
+func approvalDigest(project string, wv WaveT, tsk TaskT) (string, error) {
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\n%v\n%v\n", project, tsk.Name, waveKey(wv))
+
+	for _, vtsk := range variantTasks(tsk) {
+		langs := templateLanguages(project, vtsk)
+		sort.Strings(langs)
+		if len(langs) == 0 {
+			return "", fmt.Errorf("%v-%v: no templates", project, vtsk.Name)
+		}
+		for _, lang := range langs {
+			rec := syntheticRecipient(lang, nil)
+			rec.SetDerived(project, &wv, &vtsk) // stale closing dates are checked in preflight
+			subject, body, err := renderText(rec, project, wv, vtsk, lang)
+			if err != nil {
+				return "", fmt.Errorf("%v-%v %v: %w", project, vtsk.Name, lang, err)
+			}
+			fmt.Fprintf(h, "%v\n%v\n%v\n%v\n", vtsk.variant, lang, subject, body)
+		}
+	}
+
+	for _, att := range tsk.Attachments {
+		f, err := os.Open(attachmentPath(project, att))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%v\n%v\n%v\n", att.Label, att.Filename, att.Language)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// approvalToken is the HMAC of project, task, wave and digest;
+// empty without key
+func approvalToken(project, task, wave, digest string) string {
+	key := os.Getenv(approvalKeyEnv)
+	if key == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%v\n%v\n%v\n%v", project, task, wave, digest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// approve writes the approval file; returns it
+func approve(project string, wv WaveT, tsk TaskT) (approvalT, error) {
+
+	digest, err := approvalDigest(project, wv, tsk)
+	if err != nil {
+		return approvalT{}, err
+	}
+	a := approvalT{
+		Project:    project,
+		Task:       tsk.Name,
+		Wave:       waveKey(wv),
+		Digest:     digest,
+		ApprovedBy: operator(),
+		ApprovedAt: time.Now().In(loc),
+		Token:      approvalToken(project, tsk.Name, waveKey(wv), digest),
+	}
+	bts, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return a, err
+	}
+	if err := os.MkdirAll(approvalDir, 0755); err != nil {
+		return a, err
+	}
+	return a, os.WriteFile(approvalFile(project, tsk.Name, a.Wave), bts, 0644)
+}
+
+// checkApproval is required before unattended prod runs;
+// token - from flag -approval - supersedes the approval file
+func checkApproval(project string, wv WaveT, tsk TaskT, token string) error {
+
+	label := fmt.Sprintf("%v-%v wave %v", project, tsk.Name, waveKey(wv))
+	digest, err := approvalDigest(project, wv, tsk)
+	if err != nil {
+		return fmt.Errorf("approval %v: %w", label, err)
+	}
+	expected := approvalToken(project, tsk.Name, waveKey(wv), digest)
+
+	if token != "" {
+		if expected == "" {
+			return fmt.Errorf("approval %v: -approval token requires env %v", label, approvalKeyEnv)
+		}
+		if !hmac.Equal([]byte(token), []byte(expected)) {
+			return fmt.Errorf("approval %v: token invalid - other wave or content changed since approval?", label)
+		}
+		log.Printf("  approved by token")
+		return nil
+	}
+
+	fn := approvalFile(project, tsk.Name, waveKey(wv))
+	bts, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("approval %v: missing; review the preview, then\n\tgo-massmail approve -project=%v -task=%v -wave=%v",
+			label, project, tsk.Name, waveKey(wv))
+	}
+	if err != nil {
+		return fmt.Errorf("approval %v: %w", label, err)
+	}
+	a := approvalT{}
+	if err := json.Unmarshal(bts, &a); err != nil {
+		return fmt.Errorf("approval %v: %v: %w", label, fn, err)
+	}
+	if a.Digest != digest {
+		return fmt.Errorf("approval %v: outdated - templates or attachments changed since %v", label, a.ApprovedAt.Format("2006-01-02 15:04"))
+	}
+	if expected != "" && !hmac.Equal([]byte(a.Token), []byte(expected)) {
+		return fmt.Errorf("approval %v: token in %v invalid", label, fn)
+	}
+	log.Printf("  approved by %v at %v", a.ApprovedBy, a.ApprovedAt.Format("2006-01-02 15:04"))
+	return nil
+}
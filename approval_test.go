@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestApprovalRoundTrip approves tasks as configured
+// and checks them as dueTasks() passes them to the run
+func TestApprovalRoundTrip(t *testing.T) {
+
+	prevCfg, prevLoc, prevDir := cfg, loc, approvalDir
+	defer func() { cfg, loc, approvalDir = prevCfg, prevLoc, prevDir }()
+	loc = time.UTC
+
+	t.Chdir(t.TempDir())
+	t.Setenv(approvalKeyEnv, "secret")
+	approvalDir = "approvals"
+
+	if err := os.MkdirAll(filepath.Join("tpl", "p"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTpl := func(body string) {
+		if err := os.WriteFile(filepath.Join("tpl", "p", "reminder-de.md"), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTpl("Erinnerung {{.MonthYear}}\n\n{{.Anrede}},\n\nbitte nehmen Sie teil.\n")
+
+	cfg = configT{
+		Projects: map[string]ProjectT{"p": {
+			Blackout: &BlackoutT{Weekends: true},
+		}},
+	}
+	wv := WaveT{Year: 2026, Month: time.July}
+	other := WaveT{Year: 2026, Month: time.August}
+
+	scheduled := TaskT{Name: "reminder", ExecutionTime: time.Date(2026, 7, 18, 10, 0, 0, 0, loc)} // Saturday
+	interval := TaskT{Name: "reminder", ExecutionInterval: "every weekday 10:30"}
+
+	// as dueTasks() sets the execution time
+	shifted := scheduled
+	shifted.ExecutionTime, _ = blackoutTime("p", scheduled, scheduled.ExecutionTime)
+	if shifted.ExecutionTime.Weekday() != time.Monday {
+		t.Fatalf("blackout: %v not shifted to Monday", shifted.ExecutionTime)
+	}
+	fired := interval
+	sc, err := taskInterval(interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fired.ExecutionTime = sc.Prev(time.Date(2026, 7, 22, 12, 0, 0, 0, loc))
+	catchUp := scheduled
+	catchUp.ExecutionTime = time.Date(2026, 7, 20, 10, 0, 0, 0, loc)
+
+	tests := []struct {
+		desc     string
+		approved TaskT
+		run      TaskT
+		wv       WaveT
+		token    bool
+		wantErr  bool
+	}{
+		{"shifted by blackout", scheduled, shifted, wv, false, false},
+		{"shifted by blackout - token", scheduled, shifted, wv, true, false},
+		{"interval fire", interval, fired, wv, false, false},
+		{"interval fire - token", interval, fired, wv, true, false},
+		{"catch up", scheduled, catchUp, wv, true, false},
+		{"other wave", scheduled, shifted, other, false, true},
+		{"other wave - token", scheduled, shifted, other, true, true},
+	}
+	for _, tt := range tests {
+		os.RemoveAll(approvalDir)
+		a, err := approve("p", wv, tt.approved)
+		if err != nil {
+			t.Fatalf("%v: approve: %v", tt.desc, err)
+		}
+		token := ""
+		if tt.token {
+			token = a.Token
+		}
+		err = checkApproval("p", tt.wv, tt.run, token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: check: %v; want error %v", tt.desc, err, tt.wantErr)
+		}
+	}
+
+	// content changed after approval
+	a, err := approve("p", wv, scheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTpl("Erinnerung {{.MonthYear}}\n\n{{.Anrede}},\n\nbitte nehmen Sie heute teil.\n")
+	if err := checkApproval("p", wv, shifted, ""); err == nil {
+		t.Errorf("changed template: approval file accepted")
+	}
+	if err := checkApproval("p", wv, shifted, a.Token); err == nil {
+		t.Errorf("changed template: token accepted")
+	}
+}
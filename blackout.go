@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BlackoutT is a per project calendar of days without sending.
+//
+//	"blackout": {
+//	  "holidays": "de-bw",
+//	  "weekends": true,
+//	  "ranges": [
+//	    {"from": "12-22", "to": "01-06", "description": "christmas shutdown"},
+//	    {"from": "2026-08-03", "to": "2026-08-14", "description": "summer break"}
+//	  ],
+//	  "action": "shift"
+//	}
+//
+// Scheduled runs on blackout days are shifted to the next free day
+// at the same clock time - or blocked.
+type BlackoutT struct {
+	Holidays string           `json:"holidays,omitempty"` // "de", "de-bw" - empty means none
+	Weekends bool             `json:"weekends,omitempty"`
+	Ranges   []BlackoutRangeT `json:"ranges,omitempty"`
+	Action   string           `json:"action,omitempty"` // "shift" - default, or "block"
+}
+
+// BlackoutRangeT - inclusive; 2026-12-22 or 12-22 for every year
+type BlackoutRangeT struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Description string `json:"description,omitempty"`
+}
+
+// blackoutMaxShift - a run is blocked, if no free day follows within
+const blackoutMaxShift = 31
+
+// contains checks day d - yearly ranges may wrap around new year
+func (br BlackoutRangeT) contains(d time.Time) bool {
+	if len(br.From) == len("01-02") {
+		md := d.Format("01-02")
+		if br.From <= br.To {
+			return br.From <= md && md <= br.To
+		}
+		return br.From <= md || md <= br.To
+	}
+	day := d.Format("2006-01-02")
+	return br.From <= day && day <= br.To
+}
+
+func (br BlackoutRangeT) valid() error {
+	layout := "2006-01-02"
+	if len(br.From) == len("01-02") {
+		layout = "01-02"
+	}
+	if len(br.To) != len(layout) {
+		return fmt.Errorf("range %v - %v: from and to must have the same format", br.From, br.To)
+	}
+	for _, s := range []string{br.From, br.To} {
+		if _, err := time.Parse(layout, s); err != nil {
+			return fmt.Errorf("range %v - %v: %w", br.From, br.To, err)
+		}
+	}
+	if layout == "2006-01-02" && br.From > br.To {
+		return fmt.Errorf("range %v - %v: from after to", br.From, br.To)
+	}
+	return nil
+}
+
+// reason returns why day t is blacked out - or empty string
+func (b *BlackoutT) reason(t time.Time) string {
+	if b == nil {
+		return ""
+	}
+	t = t.In(loc)
+	if b.Weekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return "weekend"
+	}
+	if b.Holidays != "" {
+		if name := holidayName(t, b.Holidays, nil); name != "" {
+			return name
+		}
+	}
+	for _, br := range b.Ranges {
+		if br.contains(t) {
+			if br.Description != "" {
+				return br.Description
+			}
+			return fmt.Sprintf("blackout %v - %v", br.From, br.To)
+		}
+	}
+	return ""
+}
+
+// blackoutAction - task setting supersedes project setting
+func blackoutAction(project string, tsk TaskT) string {
+	if tsk.Blackout != "" {
+		return tsk.Blackout
+	}
+	if b := cfg.Projects[project].Blackout; b != nil && b.Action != "" {
+		return b.Action
+	}
+	return "shift"
+}
+
+// blackoutTime applies the project blackout calendar to scheduled time t.
+// Returns the effective time - zero, if blocked - and a note for listings;
+// the note is empty, if t is unaffected.
+func blackoutTime(project string, tsk TaskT, t time.Time) (time.Time, string) {
+
+	b := cfg.Projects[project].Blackout
+	action := blackoutAction(project, tsk)
+	if b == nil || action == "ignore" || t.IsZero() {
+		return t, ""
+	}
+	why := b.reason(t)
+	if why == "" {
+		return t, ""
+	}
+	if action == "block" {
+		return time.Time{}, fmt.Sprintf("blocked - %v", why)
+	}
+	eff := t
+	for i := 0; i < blackoutMaxShift; i++ {
+		eff = eff.AddDate(0, 0, 1)
+		if b.reason(eff) == "" {
+			return eff, fmt.Sprintf("shifted from %v - %v", t.In(loc).Format("Mon 01-02"), why)
+		}
+	}
+	return time.Time{}, fmt.Sprintf("blocked - %v - no free day within %v days", why, blackoutMaxShift)
+}
+
+// blackoutPrev returns the effective time of the latest fire of sc,
+// whose effective time is at or before nw - with its original fire time and note;
+// shifted fires are found up to blackoutMaxShift days back;
+// a fire time, shifted onto another fire, is merged into it
+func blackoutPrev(project string, tsk TaskT, sc *schedT, nw time.Time) (eff, fire time.Time, note string) {
+	limit := nw.AddDate(0, 0, -blackoutMaxShift-1)
+	for fire = sc.Prev(nw); !fire.IsZero() && fire.After(limit); fire = sc.Prev(fire.Add(-time.Minute)) {
+		eff, note = blackoutTime(project, tsk, fire)
+		if !eff.IsZero() && !eff.After(nw) {
+			return eff, fire, note
+		}
+	}
+	return time.Time{}, time.Time{}, ""
+}
+
+// blackoutNext returns the first effective time of sc after nw
+func blackoutNext(project string, tsk TaskT, sc *schedT, nw time.Time) (eff time.Time, note string) {
+	fire := nw
+	for i := 0; i < 1000; i++ {
+		fire = sc.Next(fire)
+		if fire.IsZero() {
+			break
+		}
+		eff, note = blackoutTime(project, tsk, fire)
+		if eff.After(nw) {
+			return eff, note
+		}
+	}
+	return time.Time{}, ""
+}
+
+// checkBlackouts validates project calendars and task actions
+func checkBlackouts() error {
+	for project, prj := range cfg.Projects {
+		b := prj.Blackout
+		if b == nil {
+			continue
+		}
+		if b.Holidays != "" && !holidayRegions[strings.ToLower(b.Holidays)] {
+			return fmt.Errorf("project %v blackout: unknown holiday region %q", project, b.Holidays)
+		}
+		switch b.Action {
+		case "", "shift", "block":
+		default:
+			return fmt.Errorf("project %v blackout: action %q - want shift or block", project, b.Action)
+		}
+		for _, br := range b.Ranges {
+			if err := br.valid(); err != nil {
+				return fmt.Errorf("project %v blackout: %w", project, err)
+			}
+		}
+	}
+	for project, tsks := range cfg.Tasks {
+		for _, tsk := range tsks {
+			switch tsk.Blackout {
+			case "", "shift", "block", "ignore":
+			default:
+				return fmt.Errorf("%v-%v: blackout %q - want shift, block or ignore", project, tsk.Name, tsk.Blackout)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,659 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Subcommands - nothing is loaded, downloaded or written before a command is chosen.
+//
+//	go-massmail [-config=config.json] <command> [flags]
+//
+// Exit codes
+const (
+	exitOK    = 0
+	exitFail  = 1 // task errors, lint findings, invalid config
+	exitUsage = 2 // unknown command or invalid flags
+)
+
+// commandT is a subcommand
+type commandT struct {
+	name  string
+	short string
+	run   func(args []string) int
+}
+
+var commands []commandT
+
+func init() {
+	// assigned in init - the help command references commands
+	commands = []commandT{
+		{"run", "send due tasks - the daily cron job; or a single task by -project and -task", cmdRun},
+		{"due", "list due, blocked and overdue tasks - nothing is sent", cmdDue},
+		{"send-test", "send a task to its test recipients now - regardless of execution time", cmdSendTest},
+		{"preview", "render emails of a task to .eml files and index.html", cmdPreview},
+		{"approve", "approve a task for unattended prod runs - after reviewing the preview", cmdApprove},
+		{"lint", "check templates and recipient files of all tasks", cmdLint},
+		{"validate-config", "load and validate the config", cmdValidateConfig},
+		{"recipients", "list the recipients of a task with derived fields", cmdRecipients},
+		{"suppress", "list or add unsubscribed email addresses", cmdSuppress},
+		{"report", "show run history and overdue tasks", cmdReport},
+		{"schedule", "explain the next fire times of tasks or an interval", cmdSchedule},
+		{"serve", "start the local preview server", cmdServe},
+		{"daemon", "stay running and send tasks when due", cmdDaemon},
+		{"help", "show help for a command", cmdHelp},
+	}
+}
+
+func commandByName(name string) (commandT, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return commandT{}, false
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: go-massmail [-config=config.json] <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-16v %v\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\ngo-massmail help <command> shows the flags of a command\n")
+}
+
+// newFlagSet - every command accepts -config
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", configFile, "path to config file")
+	fs.Usage = func() {
+		c, _ := commandByName(name)
+		fmt.Fprintf(fs.Output(), "go-massmail %v - %v\n\nflags:\n", name, c.short)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags returns an exit code, if parsing failed or help was requested
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments %v\n", fs.Args())
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+func projectTaskFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flagProject, "project", "", "project - i.e. fmt")
+	fs.StringVar(&flagTask, "task", "", "task name - i.e. reminder")
+}
+
+// attendanceFlags returns a func, which sets unattended after parsing;
+// unattended must be explicit - existing cron jobs run without approval as before;
+// without terminal on stdin, there is no key control anyway
+func attendanceFlags(fs *flag.FlagSet) func() error {
+	inter := fs.Bool("interactive", false, "menu and prompts on stdin - default")
+	unatt := fs.Bool("unattended", false, "no stdin - for cron and systemd; prod runs require an approval")
+	return func() error {
+		switch {
+		case *inter && *unatt:
+			return fmt.Errorf("-interactive and -unattended exclude each other")
+		case *unatt:
+			unattended = true
+		default:
+			unattended = false
+		}
+		if unattended {
+			log.Printf("	unattended - no menu, no prompts")
+		} else if !term.IsTerminal(int(os.Stdin.Fd())) {
+			log.Printf("	stdin is no terminal - no menu; -unattended requires approvals for prod runs")
+		}
+		return nil
+	}
+}
+
+func waveFlag(fs *flag.FlagSet) {
+	fs.StringVar(&flagWave, "wave", "", "wave year and month - i.e. 2025-11; default is the wave containing the execution time")
+}
+
+// setup loads the config, sets mode and start time and generates recurring waves;
+// start is 2006-01-02T15:04 - or empty for now
+func setup(mode, start string) error {
+
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	operationMode = mode
+	log.Printf("\tmode is %q\n", mode)
+
+	if start == "" {
+		startTime = time.Now().In(loc).Truncate(time.Minute)
+	} else {
+		log.Printf("\tstart time %q\n", start)
+		var err error
+		startTime, err = time.ParseInLocation(stfmt, start, loc)
+		if err != nil {
+			return fmt.Errorf("start must be parseable '2006-01-02T15:04', was %q\n\t%w", start, err)
+		}
+		dist := time.Until(startTime) // more succint, but less explicit than   startTime.Sub(time.Now())
+		if dist < -60*time.Second {
+			return fmt.Errorf("start time %q is %d secs in the past", start, dist/time.Second)
+		}
+		if dist > 24*time.Hour {
+			return fmt.Errorf("start time cannot be more than 24 hours in the future; %q", start)
+		}
+	}
+
+	// requiring startTime
+	return generateWaves()
+}
+
+// legacyArgs translates -mode=xxx into a command
+//
+//	-mode=prod      =>  run -mode=prod
+//	-mode=preview   =>  preview
+//
+// Arguments containing a command are returned unchanged -
+// i.e. -config=config.json run -mode=test
+func legacyArgs(args []string) []string {
+	for idx := 0; idx < len(args); idx++ {
+		if name := strings.TrimLeft(args[idx], "-"); name == "mode" || name == "config" {
+			idx++ // value of -mode preview
+			continue
+		}
+		if _, ok := commandByName(args[idx]); ok {
+			return args
+		}
+	}
+	for idx, arg := range args {
+		name, val, ok := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "mode" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		rest := append([]string{}, args[:idx]...)
+		if !ok && idx+1 < len(args) {
+			val = args[idx+1]
+			rest = append(rest, args[idx+2:]...)
+		} else {
+			rest = append(rest, args[idx+1:]...)
+		}
+		if val == "test" || val == "prod" {
+			log.Printf("\t-mode=%v is deprecated; use: go-massmail run -mode=%v", val, val)
+			return append([]string{"run", "-mode=" + val}, rest...)
+		}
+		log.Printf("\t-mode=%v is deprecated; use: go-massmail %v", val, val)
+		return append([]string{val}, rest...)
+	}
+	return args
+}
+
+func main() {
+
+	log.SetFlags(log.Lshortfile | log.Ltime)
+
+	args := legacyArgs(os.Args[1:])
+
+	// global flags before the command
+	fs := flag.NewFlagSet("go-massmail", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", configFile, "path to config file")
+	fs.Usage = func() { usage(fs.Output()) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+	args = fs.Args()
+
+	if len(args) < 1 {
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+	c, ok := commandByName(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+	os.Exit(c.run(args[1:]))
+}
+
+func cmdHelp(args []string) int {
+	if len(args) < 1 {
+		usage(os.Stdout)
+		return exitOK
+	}
+	c, ok := commandByName(args[0])
+	if !ok || c.name == "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return exitUsage
+	}
+	return c.run([]string{"-h"})
+}
+
+func cmdRun(args []string) int {
+	fs := newFlagSet("run")
+	mode := fs.String("mode", "", "test or prod - required")
+	start := fs.String("start", "", "defer until date and time 2006-01-02T15:04; default now")
+	waveFlag(fs)
+	fs.StringVar(&flagProject, "project", "", "project - with -task: run this task regardless of its execution time")
+	fs.StringVar(&flagTask, "task", "", "task name - with -project")
+	attendance := attendanceFlags(fs)
+	fs.StringVar(&flagApproval, "approval", "", "approval token - instead of the approval file; requires env "+approvalKeyEnv)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *mode != "test" && *mode != "prod" {
+		fmt.Fprintf(os.Stderr, "mode must be 'test' or 'prod', was %q\n\tgo-massmail run -mode=test\n", *mode)
+		return exitUsage
+	}
+	if err := attendance(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if (flagProject == "") != (flagTask == "") {
+		fmt.Fprintf(os.Stderr, "-project and -task must be given together\n")
+		return exitUsage
+	}
+	if err := setup(*mode, *start); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	loadUnsubscribers()
+
+	// explicit task; see explicit.go
+	if flagTask != "" {
+		if err := runExplicit(flagProject, flagTask, flagWave); err != nil {
+			log.Print(err)
+			return exitFail
+		}
+		return exitOK
+	}
+
+	if failed := iterTasks(); failed > 0 {
+		return exitFail
+	}
+	return exitOK
+}
+
+func cmdDue(args []string) int {
+	fs := newFlagSet("due")
+	mode := fs.String("mode", "test", "test - including advance test runs - or prod")
+	start := fs.String("start", "", "date and time 2006-01-02T15:04; default now")
+	waveFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *mode != "test" && *mode != "prod" {
+		fmt.Fprintf(os.Stderr, "mode must be 'test' or 'prod', was %q\n", *mode)
+		return exitUsage
+	}
+	if err := setup(*mode, *start); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	dueListing = true
+	dueTasks()
+	return exitOK
+}
+
+func cmdSendTest(args []string) int {
+	fs := newFlagSet("send-test")
+	projectTaskFlags(fs)
+	waveFlag(fs)
+	attendance := attendanceFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := attendance(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if flagProject == "" || flagTask == "" {
+		fmt.Fprintf(os.Stderr, "send-test requires -project and -task\n")
+		return exitUsage
+	}
+	if err := setup("test", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	loadUnsubscribers()
+	tsk, err := taskByName(flagProject, flagTask)
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	tsk.testmode = true
+	wv, err := taskWave(flagProject, flagWave, tsk)
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	if err := runTask(flagProject, wv, tsk); err != nil {
+		return exitFail
+	}
+	return exitOK
+}
+
+func cmdPreview(args []string) int {
+	fs := newFlagSet("preview")
+	projectTaskFlags(fs)
+	waveFlag(fs)
+	fs.StringVar(&flagIDs, "ids", "", "comma separated recipient IDs")
+	fs.StringVar(&flagOut, "out", "preview", "output directory")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("preview", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	ids := []string{}
+	if flagIDs != "" {
+		ids = strings.Split(flagIDs, ",")
+	}
+	if err := previewTask(flagProject, flagTask, flagWave, ids, flagOut); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	return exitOK
+}
+
+func cmdApprove(args []string) int {
+	fs := newFlagSet("approve")
+	projectTaskFlags(fs)
+	waveFlag(fs)
+	yes := fs.Bool("yes", false, "no confirmation prompt")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if flagProject == "" || flagTask == "" {
+		fmt.Fprintf(os.Stderr, "approve requires -project and -task\n")
+		return exitUsage
+	}
+	if err := setup("preview", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	tsk, err := taskByName(flagProject, flagTask)
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	wv, _, err := selectWave(flagProject, flagWave, tsk, taskTime(tsk))
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	if !*yes {
+		answer, err := userStdin(fmt.Sprintf("approve %v-%v wave %v for prod sending - preview reviewed? [y/N] ",
+			flagProject, tsk.Name, waveKey(wv)))
+		if err != nil || !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			log.Print("not approved")
+			return exitFail
+		}
+	}
+	a, err := approve(flagProject, wv, tsk)
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	log.Printf("approved %v-%v wave %v - %v", a.Project, a.Task, a.Wave, approvalFile(a.Project, a.Task, a.Wave))
+	if a.Token != "" {
+		fmt.Fprintf(os.Stdout, "%v\n", a.Token)
+	}
+	return exitOK
+}
+
+func cmdLint(args []string) int {
+	fs := newFlagSet("lint")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("lint", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	if lintAll() > 0 {
+		return exitFail
+	}
+	return exitOK
+}
+
+func cmdValidateConfig(args []string) int {
+	fs := newFlagSet("validate-config")
+	example := fs.Bool("example", false, "write example-config.json")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *example {
+		writeExampleConfig()
+	}
+	if err := setup("lint", ""); err != nil {
+		log.Printf("%v invalid: %v", configFile, err)
+		return exitFail
+	}
+	tasks := 0
+	for _, tsks := range cfg.Tasks {
+		tasks += len(tsks)
+	}
+	log.Printf("%v is valid - %v projects, %v tasks, time zone %v", configFile, len(cfg.Projects), tasks, loc)
+	return exitOK
+}
+
+func cmdRecipients(args []string) int {
+	fs := newFlagSet("recipients")
+	projectTaskFlags(fs)
+	waveFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if flagProject == "" || flagTask == "" {
+		fmt.Fprintf(os.Stderr, "recipients requires -project and -task\n")
+		return exitUsage
+	}
+	if err := setup("test", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	loadUnsubscribers()
+	tsk, err := taskByName(flagProject, flagTask)
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	wv, _, err := selectWave(flagProject, flagWave, tsk, taskTime(tsk))
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	recs, err := getCSV(flagProject, wv, tsk, false)
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	w := os.Stdout
+	fmt.Fprintf(w, "%-6v %-36v %-4v %-8v %-10v %v\n", "id", "email", "lang", "variant", "excluded", "salutation")
+	excluded := 0
+	for _, rec := range recs {
+		ex := ""
+		if strings.Contains(rec.NoMail, "noMail") {
+			ex = "noMail"
+			excluded++
+		}
+		fmt.Fprintf(w, "%-6v %-36v %-4v %-8v %-10v %v\n", rec.ID, rec.Email, rec.Language, rec.Variant, ex, rec.Anrede)
+	}
+	fmt.Fprintf(w, "%v recipients - %v excluded\n", len(recs), excluded)
+	return exitOK
+}
+
+func cmdSuppress(args []string) int {
+	fs := newFlagSet("suppress")
+	projectTaskFlags(fs)
+	add := fs.String("add", "", "email address to add to "+suppressFile+" - for -project and -task; task defaults to all")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("test", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+
+	if *add != "" {
+		if flagProject == "" {
+			fmt.Fprintf(os.Stderr, "suppress -add requires -project\n")
+			return exitUsage
+		}
+		task := flagTask
+		if task == "" {
+			task = "all"
+		}
+		if err := appendSuppression(flagProject, task, *add); err != nil {
+			log.Print(err)
+			return exitFail
+		}
+		log.Printf("suppressed %v for %v-%v", *add, flagProject, task)
+		return exitOK
+	}
+
+	loadUnsubscribers()
+	projects := []string{}
+	for project := range unsubscribers {
+		if flagProject == "" || project == flagProject {
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	for _, project := range projects {
+		tasks := []string{}
+		for task := range unsubscribers[project] {
+			if flagTask == "" || task == flagTask {
+				tasks = append(tasks, task)
+			}
+		}
+		sort.Strings(tasks)
+		for _, task := range tasks {
+			emails := []string{}
+			for email := range unsubscribers[project][task] {
+				emails = append(emails, email)
+			}
+			sort.Strings(emails)
+			for _, email := range emails {
+				fmt.Fprintf(os.Stdout, "%-12v %-28v %v\n", project, task, email)
+			}
+		}
+	}
+	return exitOK
+}
+
+func cmdReport(args []string) int {
+	fs := newFlagSet("report")
+	projectTaskFlags(fs)
+	n := fs.Int("n", 20, "number of history records")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("test", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	hist, err := readRunHistory()
+	if err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	sel := []runRecordT{}
+	for _, rr := range hist {
+		if (flagProject == "" || rr.Project == flagProject) && (flagTask == "" || rr.Task == flagTask) {
+			sel = append(sel, rr)
+		}
+	}
+	if len(sel) > *n {
+		sel = sel[len(sel)-*n:]
+	}
+	w := os.Stdout
+	fmt.Fprintf(w, "%-17v %-32v %-8v %-17v %-10v %v\n", "time", "task", "wave", "due", "status", "kind")
+	for _, rr := range sel {
+		due := ""
+		if !rr.Due.IsZero() {
+			due = rr.Due.In(loc).Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%-17v %-32v %-8v %-17v %-10v %v\n",
+			rr.At.In(loc).Format("2006-01-02 15:04"), rr.Project+"-"+rr.Task, rr.Wave, due, rr.Status, rr.Kind)
+	}
+	failed := 0
+	for _, rr := range sel {
+		if rr.Status == "failed" {
+			failed++
+		}
+	}
+	fmt.Fprintf(w, "%v runs - %v failed\n", len(sel), failed)
+	reportOverdue(overdueTasks(startTime, hist))
+	return exitOK
+}
+
+func cmdSchedule(args []string) int {
+	fs := newFlagSet("schedule")
+	projectTaskFlags(fs)
+	fs.StringVar(&flagInterval, "interval", "", "interval to explain - i.e. \"every weekday 10:30\"")
+	fs.IntVar(&flagN, "n", 5, "number of fire times")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("schedule", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	if err := explainSchedules(flagProject, flagTask, flagInterval, flagN); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	return exitOK
+}
+
+func cmdServe(args []string) int {
+	fs := newFlagSet("serve")
+	fs.StringVar(&flagAddr, "addr", "localhost:8085", "listen address")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("serve", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	log.Print(serve(flagAddr))
+	return exitFail
+}
+
+func cmdDaemon(args []string) int {
+	fs := newFlagSet("daemon")
+	fs.StringVar(&flagAddr, "addr", "localhost:8085", "listen address of the status page; empty for none")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup("daemon", ""); err != nil {
+		log.Print(err)
+		return exitFail
+	}
+	loadUnsubscribers()
+	daemon(flagAddr)
+	return exitOK
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLegacyArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-mode=prod"}, []string{"run", "-mode=prod"}},
+		{[]string{"-mode", "test"}, []string{"run", "-mode=test"}},
+		{[]string{"-mode=preview"}, []string{"preview"}},
+		{[]string{"-mode", "preview"}, []string{"preview"}},
+		{[]string{"-config=x.json", "-mode=prod"}, []string{"run", "-mode=prod", "-config=x.json"}},
+		{[]string{"-config", "x.json", "-mode=test"}, []string{"run", "-mode=test", "-config", "x.json"}},
+
+		// -config before a command
+		{[]string{"-config=config.json", "run", "-mode=test"}, []string{"-config=config.json", "run", "-mode=test"}},
+		{[]string{"-config", "config.json", "run", "-mode", "prod"}, []string{"-config", "config.json", "run", "-mode", "prod"}},
+		{[]string{"run", "-mode=prod"}, []string{"run", "-mode=prod"}},
+		{[]string{"due"}, []string{"due"}},
+	}
+	for _, tt := range tests {
+		got := legacyArgs(tt.args)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("legacyArgs(%q) = %q; want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Outlook strips <style> blocks and most block formatting.
+// inlineCSS moves the rules of <style> blocks into the style attributes
+// of the matching elements.
+//
+// Supported selectors are tag, .class, #id and combinations thereof,
+// such as p.intro or .a.b, comma separated lists and the universal selector.
+// Rules with other selectors - descendant, pseudo classes - and @media blocks
+// cannot be inlined; they are kept in a residual <style> block.
+// @import, @font-face, @charset and comments are removed.
+
+var (
+	cssStyleBlockRe = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	cssCommentRe    = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssStartTagRe   = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^<>]*?)?)(\s*/?)>`)
+	cssAttrRe       = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+	cssSimpleSelRe  = regexp.MustCompile(`^(\*|[a-zA-Z][a-zA-Z0-9]*)?((?:[.#][-_a-zA-Z0-9]+)*)$`)
+	cssSelPartRe    = regexp.MustCompile(`[.#][-_a-zA-Z0-9]+`)
+	cssRemRe        = regexp.MustCompile(`[0-9]rem\b`)
+)
+
+// elements outside the visible body - never styled inline
+var cssSkipTags = map[string]bool{
+	"html": true, "head": true, "meta": true, "title": true,
+	"style": true, "script": true, "link": true, "base": true,
+}
+
+// CSS known to be unsupported by email clients;
+// property name => clients
+var cssUnsupported = map[string]string{
+	"float":            "Outlook",
+	"position":         "Outlook, Gmail",
+	"max-width":        "Outlook",
+	"max-height":       "Outlook",
+	"min-height":       "Outlook",
+	"box-shadow":       "Outlook",
+	"text-shadow":      "Outlook",
+	"opacity":          "Outlook",
+	"transform":        "Outlook, Gmail",
+	"transition":       "Outlook, Gmail",
+	"animation":        "Outlook, Gmail",
+	"border-radius":    "Outlook",
+	"background-image": "Outlook",
+	"font-variant":     "Outlook",
+}
+
+type cssDeclT struct {
+	Prop      string
+	Val       string
+	Important bool
+}
+
+type cssRuleT struct {
+	Selector    string
+	Specificity [3]int // ids, classes, tags
+	Tag         string
+	IDs         []string
+	Classes     []string
+	Decls       []cssDeclT
+	Order       int
+}
+
+// matches checks a simple selector against a start tag
+func (r cssRuleT) matches(tag, id string, classes map[string]bool) bool {
+	if r.Tag != "" && r.Tag != "*" && !strings.EqualFold(r.Tag, tag) {
+		return false
+	}
+	for _, i := range r.IDs {
+		if i != id {
+			return false
+		}
+	}
+	for _, c := range r.Classes {
+		if !classes[c] {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCSSDecls(s string) []cssDeclT {
+	decls := []cssDeclT{}
+	for _, d := range strings.Split(s, ";") {
+		pv := strings.SplitN(d, ":", 2)
+		if len(pv) != 2 {
+			continue
+		}
+		decl := cssDeclT{
+			Prop: strings.ToLower(strings.TrimSpace(pv[0])),
+			Val:  strings.TrimSpace(pv[1]),
+		}
+		if strings.HasSuffix(decl.Val, "!important") {
+			decl.Important = true
+			decl.Val = strings.TrimSpace(strings.TrimSuffix(decl.Val, "!important"))
+		}
+		if decl.Prop == "" || decl.Val == "" {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	return decls
+}
+
+// parseCSS splits a stylesheet into inlineable rules and residual CSS
+func parseCSS(css string) (rules []cssRuleT, residual []string, warnings []string) {
+
+	css = cssCommentRe.ReplaceAllString(css, "")
+
+	order := 0
+	for len(strings.TrimSpace(css)) > 0 {
+
+		css = strings.TrimSpace(css)
+
+		// at-rules without block
+		if strings.HasPrefix(css, "@import") || strings.HasPrefix(css, "@charset") {
+			end := strings.Index(css, ";")
+			if end < 0 {
+				end = len(css) - 1
+			}
+			warnings = append(warnings, fmt.Sprintf("removed %q", strings.TrimSpace(css[:end+1])))
+			css = css[end+1:]
+			continue
+		}
+
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break // trailing garbage
+		}
+		// find matching brace - @media contains nested blocks
+		depth, close := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					close = i
+					break
+				}
+			}
+		}
+		if close < 0 {
+			warnings = append(warnings, "unbalanced braces in <style>")
+			break
+		}
+
+		prelude := strings.TrimSpace(css[:open])
+		block := css[open+1 : close]
+		css = css[close+1:]
+
+		if strings.HasPrefix(prelude, "@font-face") {
+			warnings = append(warnings, "removed @font-face")
+			continue
+		}
+		if strings.HasPrefix(prelude, "@") {
+			residual = append(residual, prelude+" {"+block+"}")
+			continue
+		}
+
+		decls := parseCSSDecls(block)
+		for _, sel := range strings.Split(prelude, ",") {
+			sel = strings.TrimSpace(sel)
+			m := cssSimpleSelRe.FindStringSubmatch(sel)
+			if m == nil || sel == "" {
+				residual = append(residual, sel+" {"+block+"}")
+				warnings = append(warnings, fmt.Sprintf("selector %q cannot be inlined", sel))
+				continue
+			}
+			r := cssRuleT{Selector: sel, Tag: m[1], Decls: decls, Order: order}
+			order++
+			for _, part := range cssSelPartRe.FindAllString(m[2], -1) {
+				if part[0] == '#' {
+					r.IDs = append(r.IDs, part[1:])
+				} else {
+					r.Classes = append(r.Classes, part[1:])
+				}
+			}
+			r.Specificity = [3]int{len(r.IDs), len(r.Classes), 0}
+			if r.Tag != "" && r.Tag != "*" {
+				r.Specificity[2] = 1
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	return
+}
+
+// cssWarnings checks declarations against cssUnsupported
+func cssWarnings(decls []cssDeclT) []string {
+	warnings := []string{}
+	for _, d := range decls {
+		if clients, ok := cssUnsupported[d.Prop]; ok {
+			warnings = append(warnings, fmt.Sprintf("%v is not supported by %v", d.Prop, clients))
+		}
+		if d.Prop == "display" && (strings.Contains(d.Val, "flex") || strings.Contains(d.Val, "grid")) {
+			warnings = append(warnings, fmt.Sprintf("display:%v is not supported by Outlook", d.Val))
+		}
+		if cssRemRe.MatchString(d.Val) {
+			warnings = append(warnings, fmt.Sprintf("%v: rem units are not supported by Outlook", d.Prop))
+		}
+	}
+	return warnings
+}
+
+// inlineCSS returns the HTML with <style> rules inlined into style attributes,
+// and warnings about unsupported CSS
+func inlineCSS(html string) (string, []string) {
+
+	blocks := cssStyleBlockRe.FindAllStringSubmatchIndex(html, -1)
+	if len(blocks) == 0 {
+		return html, nil
+	}
+
+	css := &strings.Builder{}
+	for _, b := range blocks {
+		css.WriteString(html[b[2]:b[3]])
+		css.WriteString("\n")
+	}
+	rules, residual, warnings := parseCSS(css.String())
+
+	// remove style blocks - first one is replaced by the residual
+	sb := &strings.Builder{}
+	last := 0
+	for idx, b := range blocks {
+		sb.WriteString(html[last:b[0]])
+		if idx == 0 && len(residual) > 0 {
+			sb.WriteString("<style>\n" + strings.Join(residual, "\n") + "\n</style>")
+		}
+		last = b[1]
+	}
+	sb.WriteString(html[last:])
+	html = sb.String()
+
+	seen := map[string]bool{}
+	for _, r := range rules {
+		for _, w := range cssWarnings(r.Decls) {
+			if !seen[w] {
+				seen[w] = true
+				warnings = append(warnings, w)
+			}
+		}
+	}
+
+	// stable ordering by specificity, then source order
+	sort.SliceStable(rules, func(i, j int) bool {
+		si, sj := rules[i].Specificity, rules[j].Specificity
+		if si != sj {
+			for k := 0; k < 3; k++ {
+				if si[k] != sj[k] {
+					return si[k] < sj[k]
+				}
+			}
+		}
+		return rules[i].Order < rules[j].Order
+	})
+
+	html = cssStartTagRe.ReplaceAllStringFunc(html, func(tag string) string {
+
+		m := cssStartTagRe.FindStringSubmatch(tag)
+		name, attrs, closing := m[1], m[2], m[3]
+		if cssSkipTags[strings.ToLower(name)] {
+			return tag
+		}
+
+		id, style := "", ""
+		classes := map[string]bool{}
+		attrsWithoutStyle := &strings.Builder{}
+		for _, a := range cssAttrRe.FindAllStringSubmatch(attrs, -1) {
+			val := a[2] + a[3] + a[4]
+			switch strings.ToLower(a[1]) {
+			case "id":
+				id = val
+			case "class":
+				for _, c := range strings.Fields(val) {
+					classes[c] = true
+				}
+			case "style":
+				style = val
+				continue
+			}
+			attrsWithoutStyle.WriteString(" " + a[0])
+		}
+
+		// merge declarations; later ones override, !important wins
+		merged := []cssDeclT{}
+		pos := map[string]int{}
+		apply := func(d cssDeclT) {
+			if i, ok := pos[d.Prop]; ok {
+				if merged[i].Important && !d.Important {
+					return
+				}
+				merged[i] = d
+				return
+			}
+			pos[d.Prop] = len(merged)
+			merged = append(merged, d)
+		}
+		for _, r := range rules {
+			if r.matches(name, id, classes) {
+				for _, d := range r.Decls {
+					apply(d)
+				}
+			}
+		}
+		if len(merged) == 0 {
+			return tag
+		}
+		// existing inline style takes precedence
+		for _, d := range parseCSSDecls(style) {
+			d.Important = true
+			apply(d)
+		}
+
+		decls := make([]string, 0, len(merged))
+		for _, d := range merged {
+			decls = append(decls, d.Prop+": "+d.Val)
+		}
+		styleAttr := strings.ReplaceAll(strings.Join(decls, "; "), `"`, "'")
+		return fmt.Sprintf(`<%v%v style="%v"%v>`, name, attrsWithoutStyle, styleAttr, closing)
+	})
+
+	return html, warnings
+}
+
+var cssWarned = map[string]bool{}
+var cssWarnedMtx sync.Mutex
+
+// logCSSWarnings logs each warning only once - templates are rendered for each recipient
+func logCSSWarnings(templateName string, warnings []string) {
+	cssWarnedMtx.Lock()
+	defer cssWarnedMtx.Unlock()
+	for _, w := range warnings {
+		key := templateName + w
+		if cssWarned[key] {
+			continue
+		}
+		cssWarned[key] = true
+		log.Printf("  css %v: %v", templateName, w)
+	}
+}
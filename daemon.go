@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The daemon stays running - instead of a cron job around 10:30.
+// It computes upcoming tasks from config, sleeps until each is due,
+// runs the test sends 24 hours in advance, and reloads the config
+// on SIGHUP or when config.json changes.
+// Its schedule and status are served as JSON.
+
+// daemonLead - prod runs are started early; preflight and menu precede the precise start time
+const daemonLead = 2 * time.Minute
+
+// failed runs are retried after daemonBackoff, doubling, up to daemonRetries times -
+// unless messages were sent already
+const (
+	daemonBackoff = 5 * time.Minute
+	daemonRetries = 4
+)
+
+// daemonEventT is an upcoming or finished run
+type daemonEventT struct {
+	At       time.Time `json:"at"`
+	Project  string    `json:"project"`
+	Task     string    `json:"task"`
+	Test     bool      `json:"test"` // advance run to test recipients
+	Retry    time.Time `json:"retry,omitzero"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (ev daemonEventT) key() string {
+	return fmt.Sprintf("%v-%v-%v-%v", ev.Project, ev.Task, ev.Test, ev.At.Unix())
+}
+
+// wake - prod runs start daemonLead early; retries not before their backoff
+func (ev daemonEventT) wake() time.Time {
+	w := ev.At
+	if !ev.Test {
+		w = w.Add(-daemonLead)
+	}
+	if ev.Retry.After(w) {
+		w = ev.Retry
+	}
+	return w
+}
+
+// daemonRetryT - a failed event
+type daemonRetryT struct {
+	attempts int
+	at       time.Time
+}
+
+// daemonStatusT is served by /status
+type daemonStatusT struct {
+	Started     time.Time      `json:"started"`
+	ConfigAt    time.Time      `json:"config_loaded"`
+	ReloadError string         `json:"reload_error,omitempty"`
+	Running     *daemonEventT  `json:"running,omitempty"`
+	Upcoming    []daemonEventT `json:"upcoming"`
+	History     []daemonEventT `json:"history"` // most recent first
+}
+
+var daemonStatus = daemonStatusT{}
+var daemonMtx sync.Mutex
+
+// upcomingRuns computes prod and test runs after since,
+// which are not handled yet; pending retries are taken from retries
+func upcomingRuns(since time.Time, handled map[string]time.Time, retries map[string]daemonRetryT) []daemonEventT {
+
+	evs := []daemonEventT{}
+	add := func(ev daemonEventT) {
+		if _, ok := handled[ev.key()]; ok {
+			return
+		}
+		ev.Retry = retries[ev.key()].at
+		evs = append(evs, ev)
+	}
+	for project, tsks := range cfg.Tasks {
+		for _, tsk := range tsks {
+			var next time.Time
+			if !intervalDisabled(tsk.ExecutionInterval) {
+				sc, err := taskInterval(tsk)
+				if err != nil || sc.always {
+					continue // "daily" means every run - meaningless for the daemon
+				}
+				// the first fire, which is not handled yet
+				next, _ = blackoutNext(project, tsk, sc, since)
+				for i := 0; i < 1000 && !next.IsZero(); i++ {
+					ev := daemonEventT{At: next, Project: project, Task: tsk.Name}
+					if _, ok := handled[ev.key()]; !ok {
+						break
+					}
+					next, _ = blackoutNext(project, tsk, sc, next)
+				}
+			} else if eff, _ := blackoutTime(project, tsk, tsk.ExecutionTime); eff.After(since) {
+				next = eff
+			}
+			if next.IsZero() {
+				continue
+			}
+			add(daemonEventT{At: next, Project: project, Task: tsk.Name})
+			if advance := next.AddDate(0, 0, -1); advance.After(since) {
+				add(daemonEventT{At: advance, Project: project, Task: tsk.Name, Test: true})
+			}
+		}
+	}
+	sort.SliceStable(evs, func(i, j int) bool {
+		return evs[i].wake().Before(evs[j].wake())
+	})
+	return evs
+}
+
+// reloadConfig reads config.json and regenerates recurring waves;
+// on error, the previous config remains
+func reloadConfig() error {
+	prevCfg, prevLoc := cfg, loc
+	startTime = time.Now().In(loc)
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if err := generateWaves(); err != nil {
+		cfg, loc = prevCfg, prevLoc
+		return err
+	}
+	return nil
+}
+
+// runEvent executes a task via runTask - as the one-shot mode
+func runEvent(ev daemonEventT) error {
+
+	tsk, err := taskByName(ev.Project, ev.Task)
+	if err != nil {
+		log.Printf("daemon: %v", err)
+		return err
+	}
+	if ev.Test {
+		tsk.ExecutionTime = ev.At.AddDate(0, 0, 1)
+		tsk.testmode = true
+		startTime = time.Now().In(loc)
+	} else {
+		tsk.ExecutionTime = ev.At
+		startTime = ev.At
+	}
+
+	wv, err := taskWave(ev.Project, "", tsk)
+	if err != nil {
+		log.Printf("daemon: %v-%v skipping: %v", ev.Project, ev.Task, err)
+		return err
+	}
+	return runTask(ev.Project, wv, tsk)
+}
+
+func configModTime() time.Time {
+	if fi, err := os.Stat(configFile); err == nil {
+		return fi.ModTime()
+	}
+	return time.Time{}
+}
+
+// StatusH serves the daemon schedule and status
+func StatusH(w http.ResponseWriter, r *http.Request) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(daemonStatus)
+}
+
+// daemon runs until SIGINT or SIGTERM
+func daemon(addr string) {
+
+	// sending for real - test runs are marked by tsk.testmode;
+	// prod runs require approvals
+	operationMode = "prod"
+	unattended = true
+
+	daemonMtx.Lock()
+	daemonStatus.Started = time.Now().In(loc)
+	daemonStatus.ConfigAt = daemonStatus.Started
+	daemonMtx.Unlock()
+
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", StatusH)
+		mux.HandleFunc("/status", StatusH)
+		go func() {
+			log.Printf("daemon: status at http://%v/status", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				log.Printf("daemon: status server: %v", err)
+			}
+		}()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// polling - no file system notification across platforms
+	changed := make(chan struct{}, 1)
+	go func() {
+		modTime := configModTime()
+		for range time.Tick(10 * time.Second) {
+			if mt := configModTime(); !mt.Equal(modTime) {
+				modTime = mt
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	reload := func(reason string) {
+		log.Printf("daemon: reloading config - %v", reason)
+		err := reloadConfig()
+		daemonMtx.Lock()
+		defer daemonMtx.Unlock()
+		if err != nil {
+			log.Printf("daemon: reload failed; keeping previous config: %v", err)
+			daemonStatus.ReloadError = err.Error()
+			return
+		}
+		daemonStatus.ConfigAt = time.Now().In(loc)
+		daemonStatus.ReloadError = ""
+	}
+
+	run := func(ev daemonEventT) error {
+		ev.Started = time.Now().In(loc)
+		daemonMtx.Lock()
+		daemonStatus.Running = &ev
+		daemonMtx.Unlock()
+
+		err := runEvent(ev)
+
+		ev.Finished = time.Now().In(loc)
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		daemonMtx.Lock()
+		daemonStatus.Running = nil
+		daemonStatus.History = append([]daemonEventT{ev}, daemonStatus.History...)
+		if len(daemonStatus.History) > 100 {
+			daemonStatus.History = daemonStatus.History[:100]
+		}
+		daemonMtx.Unlock()
+		return err
+	}
+
+	// runs missed while the daemon was down; see history.go
+	hist, err := readRunHistory()
+	if err != nil {
+		log.Printf("daemon: %v", err)
+	}
+	nw := time.Now().In(loc)
+	ods := overdueTasks(nw, hist)
+	reportOverdue(ods)
+	for _, od := range ods {
+		if catchUp(od, nw, false) {
+			run(daemonEventT{At: od.Due, Project: od.Project, Task: od.Task.Name})
+		}
+	}
+
+	// events are run once - or retried after failure;
+	// several events may share one execution time
+	handled := map[string]time.Time{} // key - execution time
+	retries := map[string]daemonRetryT{}
+	started := time.Now().In(loc)
+
+	for {
+		// a run may block past the execution time of others;
+		// these remain upcoming until handled
+		since := time.Now().In(loc).Add(-24 * time.Hour)
+		if since.Before(started) {
+			since = started
+		}
+		for key, at := range handled {
+			if at.Before(since) {
+				delete(handled, key)
+			}
+		}
+		evs := upcomingRuns(since, handled, retries)
+		daemonMtx.Lock()
+		daemonStatus.Upcoming = evs
+		daemonMtx.Unlock()
+
+		wait := time.Hour
+		if len(evs) > 0 {
+			wait = max(time.Until(evs[0].wake()), 0)
+			log.Printf("daemon: next %v-%v test %v at %v - sleeping %v",
+				evs[0].Project, evs[0].Task, evs[0].Test, evs[0].At.Format("Mon 2006-01-02 15:04"), formatDuration(wait))
+		} else {
+			log.Printf("daemon: no upcoming tasks")
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+			wake := time.Now().In(loc)
+			for _, ev := range evs {
+				if ev.wake().After(wake) {
+					break // sorted by wake
+				}
+				key := ev.key()
+				err := run(ev)
+				if err == nil || errors.Is(err, errTaskSkipped) || errors.Is(err, errPartialRun) {
+					handled[key] = ev.At
+					delete(retries, key)
+					continue
+				}
+				r := retries[key]
+				r.attempts++
+				if r.attempts > daemonRetries {
+					log.Printf("daemon: %v-%v failed %v times - giving up", ev.Project, ev.Task, r.attempts)
+					handled[key] = ev.At
+					delete(retries, key)
+					continue
+				}
+				backoff := daemonBackoff << (r.attempts - 1)
+				r.at = time.Now().In(loc).Add(backoff)
+				retries[key] = r
+				log.Printf("daemon: %v-%v failed - retry %v of %v in %v",
+					ev.Project, ev.Task, r.attempts, daemonRetries, formatDuration(backoff))
+			}
+
+			// next occurrences of recurring waves
+			reload("after run")
+
+		case <-hup:
+			timer.Stop()
+			reload("SIGHUP")
+
+		case <-changed:
+			timer.Stop()
+			reload("config.json changed")
+
+		case sig := <-stop:
+			timer.Stop()
+			log.Printf("daemon: %v - stopping", sig)
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A task can be run explicitly by name - regardless of its execution time:
+//
+//	go-massmail run -mode=prod -project=fmt -task=reminder [-wave=2026-07]
+//
+// The operator has to confirm - unattended, an approval is required instead;
+// each attempt is recorded in csv/run-audit.csv.
+// Preflight, menu, waiting for -start and sending are the same as for due tasks.
+
+var auditFile = filepath.Join(".", "csv", "run-audit.csv")
+
+// operator is the OS user - for the audit log
+func operator() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return "unknown"
+}
+
+// appendAudit records an explicit run; outcome is declined, delivered, failed, skipped or aborted
+func appendAudit(project, task, wave, outcome string) error {
+
+	writeHeader := !fileExists(auditFile)
+	f, err := os.OpenFile(auditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	defer f.Close()
+
+	host, _ := os.Hostname()
+	w := csv.NewWriter(f)
+	w.Comma = ';'
+	if writeHeader {
+		w.Write([]string{"time", "user", "host", "mode", "project", "task", "wave", "outcome"})
+	}
+	w.Write([]string{
+		time.Now().In(loc).Format(time.RFC3339),
+		operator(),
+		host,
+		operationMode,
+		project,
+		task,
+		wave,
+		outcome,
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return nil
+}
+
+// runExplicit runs a single task by name after confirmation
+func runExplicit(project, taskName, key string) error {
+
+	tsk, err := taskByName(project, taskName)
+	if err != nil {
+		return err
+	}
+	// wave and due time from the execution time of the task - as for approve;
+	// -start only sets the begin of sending
+	tsk.explicit = true
+	wv, err := taskWave(project, key, tsk)
+	if err != nil {
+		return err
+	}
+
+	audit := func(outcome string) {
+		if err := appendAudit(project, tsk.Name, waveKey(wv), outcome); err != nil {
+			log.Print(err)
+		}
+	}
+
+	recipients := "all recipients"
+	if operationMode != "prod" {
+		recipients = "test recipients"
+	}
+	prompt := fmt.Sprintf("run %v-%v for wave %v to %v at %v - regardless of execution time? [y/N] ",
+		project, tsk.Name, waveKey(wv), recipients, startTime.Format("2006-01-02 15:04"))
+	if unattended {
+		// prod runs require an approval; see approval.go
+		log.Printf("unattended - %v", strings.TrimSuffix(prompt, "? [y/N] "))
+	} else {
+		answer, err := userStdin(prompt)
+		if err != nil || !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			audit("declined")
+			return fmt.Errorf("%v-%v: not confirmed", project, tsk.Name)
+		}
+	}
+
+	err = runTask(project, wv, tsk)
+	switch {
+	case errors.Is(err, errTaskSkipped):
+		audit("skipped")
+		return nil // by the operator
+	case errors.Is(err, errRunAborted):
+		audit("aborted")
+	case err != nil:
+		audit("failed")
+	default:
+		audit("delivered")
+	}
+	return err
+}
@@ -3,12 +3,10 @@ module github.com/zew/go-massmail
 go 1.24.0
 
 require (
-	github.com/domodwyer/mailyak v3.1.1+incompatible
+	github.com/domodwyer/mailyak/v3 v3.6.2
 	github.com/gocarina/gocsv v0.0.0-20240520201108-78e41c74b4b1
 	github.com/jackpal/gateway v1.0.7
+	golang.org/x/term v0.40.0
 )
 
-require (
-	golang.org/x/sys v0.41.0 // indirect
-	golang.org/x/term v0.40.0 // indirect
-)
+require golang.org/x/sys v0.41.0 // indirect
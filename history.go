@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prod runs are recorded in csv/run-history.csv.
+// Scheduled runs, which passed their 24 hours window without delivery -
+// i.e. the machine was down - are reported as overdue;
+// the task setting catch_up decides whether they are sent late:
+//
+//	skip     report only - default
+//	within   send, if less than catch_up_hours after the scheduled time
+//	always   send
+//	confirm  ask on stdin; the daemon does not send
+
+var runHistoryFile = filepath.Join(".", "csv", "run-history.csv")
+
+// catchUpLookback - older missed runs are ignored
+const catchUpLookback = 31 * 24 * time.Hour
+
+var errTaskSkipped = errors.New("task skipped")
+
+// errPartialRun - some messages were sent, before the run failed
+var errPartialRun = errors.New("prod run failed")
+
+// runRecordT is a row of the run history
+type runRecordT struct {
+	At      time.Time
+	Project string
+	Task    string
+	Wave    string
+	Due     time.Time
+	Status  string // delivered, failed, skipped, aborted
+	Kind    string // scheduled, explicit - see runExplicit
+}
+
+const (
+	kindScheduled = "scheduled"
+	kindExplicit  = "explicit"
+)
+
+// readRunHistory returns no records, if the file does not exist yet
+func readRunHistory() ([]runRecordT, error) {
+
+	f, err := os.Open(runHistoryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run history: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = ';'
+	r.FieldsPerRecord = -1 // kind column added later
+	hist := []runRecordT{}
+	for idx := 0; ; idx++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("run history: %w", err)
+		}
+		if idx == 0 {
+			continue // header
+		}
+		if len(row) != 6 && len(row) != 7 {
+			return nil, fmt.Errorf("run history row %v: %v fields", idx+1, len(row))
+		}
+		rr := runRecordT{Project: row[1], Task: row[2], Wave: row[3], Status: row[5], Kind: kindScheduled}
+		if len(row) == 7 && row[6] != "" {
+			rr.Kind = row[6]
+		}
+		if rr.At, err = time.Parse(time.RFC3339, row[0]); err != nil {
+			return nil, fmt.Errorf("run history row %v: %w", idx+1, err)
+		}
+		if row[4] != "" {
+			if rr.Due, err = time.Parse(time.RFC3339, row[4]); err != nil {
+				return nil, fmt.Errorf("run history row %v: %w", idx+1, err)
+			}
+		}
+		hist = append(hist, rr)
+	}
+	return hist, nil
+}
+
+func appendRunHistory(rr runRecordT) error {
+
+	writeHeader := !fileExists(runHistoryFile)
+	f, err := os.OpenFile(runHistoryFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("run history: %w", err)
+	}
+	defer f.Close()
+
+	due := ""
+	if !rr.Due.IsZero() {
+		due = rr.Due.In(loc).Format(time.RFC3339)
+	}
+	w := csv.NewWriter(f)
+	w.Comma = ';'
+	if writeHeader {
+		w.Write([]string{"time", "project", "task", "wave", "due", "status", "kind"})
+	}
+	w.Write([]string{
+		rr.At.In(loc).Format(time.RFC3339),
+		rr.Project,
+		rr.Task,
+		rr.Wave,
+		due,
+		rr.Status,
+		rr.Kind,
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("run history: %w", err)
+	}
+	return nil
+}
+
+// delivered checks the run history for a delivered run of the task scheduled at due
+func delivered(hist []runRecordT, project, task string, due time.Time) bool {
+	return runStatus(hist, project, task, due)["delivered"]
+}
+
+// runStatus collects the statuses of all scheduled runs of the task at due;
+// explicit runs do not replace the scheduled run
+func runStatus(hist []runRecordT, project, task string, due time.Time) map[string]bool {
+	st := map[string]bool{}
+	for _, rr := range hist {
+		if rr.Kind == kindExplicit {
+			continue
+		}
+		if rr.Project == project && rr.Task == task && rr.Due.Equal(due) {
+			st[rr.Status] = true
+		}
+	}
+	return st
+}
+
+// runTask executes a task using processTask and records prod runs
+func runTask(project string, wv WaveT, tsk TaskT) error {
+
+	err := processTask(project, wv, tsk)
+	status := "delivered"
+	switch {
+	case errors.Is(err, errTaskSkipped):
+		status = "skipped"
+	case errors.Is(err, errRunAborted):
+		status = "aborted"
+	case err != nil:
+		status = "failed"
+		log.Printf("%v-%v: %v", project, tsk.Name, err)
+	}
+
+	if operationMode != "prod" || tsk.testmode {
+		return err
+	}
+	rr := runRecordT{
+		At:      time.Now(),
+		Project: project,
+		Task:    tsk.Name,
+		Wave:    waveKey(wv),
+		Due:     taskTime(tsk),
+		Status:  status,
+		Kind:    kindScheduled,
+	}
+	if tsk.explicit {
+		rr.Kind = kindExplicit
+	}
+	if errHist := appendRunHistory(rr); errHist != nil {
+		log.Print(errHist)
+	}
+	return err
+}
+
+// overdueT is a scheduled run, which was never delivered
+type overdueT struct {
+	Project string
+	Task    TaskT
+	Due     time.Time
+	Failed  bool // some messages may have been sent - never resent automatically
+}
+
+// overdueTasks returns scheduled runs, whose 24 hours window has passed
+// without delivery; only runs after the first history record are considered.
+// Runs skipped or aborted by the operator count as handled.
+func overdueTasks(nw time.Time, hist []runRecordT) []overdueT {
+
+	if len(hist) == 0 {
+		return nil
+	}
+	since := hist[0].At
+	for _, rr := range hist {
+		if rr.At.Before(since) {
+			since = rr.At
+		}
+	}
+	if lb := nw.Add(-catchUpLookback); lb.After(since) {
+		since = lb
+	}
+	closed := nw.AddDate(0, 0, -1) // end of the regular window
+
+	ods := []overdueT{}
+	for project, tsks := range cfg.Tasks {
+		for _, tsk := range tsks {
+			var due time.Time
+			if !intervalDisabled(tsk.ExecutionInterval) {
+				sc, err := taskInterval(tsk)
+				if err != nil || sc.always {
+					continue
+				}
+				due, _, _ = blackoutPrev(project, tsk, sc, closed)
+			} else if eff, _ := blackoutTime(project, tsk, tsk.ExecutionTime); !eff.IsZero() && eff.Before(closed) {
+				due = eff
+			}
+			if due.IsZero() || due.Before(since) {
+				continue
+			}
+			st := runStatus(hist, project, tsk.Name, due)
+			if st["delivered"] || st["skipped"] || st["aborted"] {
+				continue
+			}
+			ods = append(ods, overdueT{Project: project, Task: tsk, Due: due, Failed: st["failed"]})
+		}
+	}
+	sort.SliceStable(ods, func(i, j int) bool {
+		return ods[i].Due.Before(ods[j].Due)
+	})
+	return ods
+}
+
+// reportOverdue logs overdue runs with their catch-up policy
+func reportOverdue(ods []overdueT) {
+	if len(ods) == 0 {
+		return
+	}
+	msg := &strings.Builder{}
+	for _, od := range ods {
+		policy := catchUpPolicy(od.Task)
+		if od.Failed {
+			policy = "confirm - run failed"
+		}
+		fmt.Fprintf(msg, "\t%v-%-22v due %v   catch up: %v\n",
+			od.Project, od.Task.Name, od.Due.In(loc).Format("Mon 2006-01-02 15:04"), policy)
+	}
+	log.Printf("%02v overdue task(s) never delivered:\n%v", len(ods), msg)
+}
+
+func catchUpPolicy(tsk TaskT) string {
+	switch tsk.CatchUp {
+	case "":
+		return "skip"
+	case "within":
+		return fmt.Sprintf("within %vh", tsk.CatchUpHours)
+	}
+	return tsk.CatchUp
+}
+
+// catchUp decides by task policy, whether an overdue run is sent now;
+// without interactive, confirm means no
+func catchUp(od overdueT, nw time.Time, interactive bool) bool {
+
+	label := fmt.Sprintf("%v-%v", od.Project, od.Task.Name)
+	policy := od.Task.CatchUp
+	if od.Failed && policy != "" && policy != "skip" {
+		// recipients may have been reached partway
+		policy = "confirm"
+		label += " (failed run)"
+	}
+	switch policy {
+	case "", "skip":
+		return false
+	case "always":
+		return true
+	case "within":
+		limit := od.Due.Add(time.Duration(od.Task.CatchUpHours) * time.Hour)
+		if nw.After(limit) {
+			log.Printf("\t%-32v catch up expired at %v", label, limit.Format("2006-01-02 15:04"))
+			return false
+		}
+		return true
+	case "confirm":
+		if !interactive {
+			log.Printf("\t%-32v catch up requires confirmation - not sending", label)
+			return false
+		}
+		answer, err := userStdin(fmt.Sprintf("send overdue %v, due %v, now? [y/N] ", label, od.Due.Format("2006-01-02 15:04")))
+		if err != nil {
+			log.Printf("\t%-32v %v - not sending", label, err)
+			return false
+		}
+		return strings.EqualFold(strings.TrimSpace(answer), "y")
+	}
+	return false
+}
+
+// checkCatchUp validates the catch-up settings of all tasks
+func checkCatchUp() error {
+	for project, tsks := range cfg.Tasks {
+		for _, tsk := range tsks {
+			switch tsk.CatchUp {
+			case "", "skip", "always", "confirm":
+			case "within":
+				if tsk.CatchUpHours <= 24 {
+					return fmt.Errorf("%v-%v: catch_up within requires catch_up_hours beyond the regular 24 hours", project, tsk.Name)
+				}
+			default:
+				return fmt.Errorf("%v-%v: catch_up %q - want skip, within, always or confirm", project, tsk.Name, tsk.CatchUp)
+			}
+		}
+	}
+	return nil
+}
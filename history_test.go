@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunHistoryKind(t *testing.T) {
+
+	prevLoc, prevFile := loc, runHistoryFile
+	defer func() { loc, runHistoryFile = prevLoc, prevFile }()
+	loc = time.UTC
+	runHistoryFile = filepath.Join(t.TempDir(), "run-history.csv")
+
+	// file written before the kind column
+	legacy := "time;project;task;wave;due;status\n" +
+		"2026-06-20T10:01:00Z;fmt;reminder;2026-06;2026-06-20T10:00:00Z;delivered\n"
+	if err := os.WriteFile(runHistoryFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	julyDue := time.Date(2026, 7, 20, 10, 0, 0, 0, loc)
+	for _, rr := range []runRecordT{
+		{At: julyDue.AddDate(0, 0, -3), Project: "fmt", Task: "reminder", Wave: "2026-07", Due: julyDue, Status: "delivered", Kind: kindExplicit},
+		{At: julyDue.AddDate(0, 0, -2), Project: "fmt", Task: "invitation", Wave: "2026-07", Due: julyDue, Status: "delivered", Kind: kindScheduled},
+	} {
+		if err := appendRunHistory(rr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hist, err := readRunHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 3 {
+		t.Fatalf("%v records; want 3", len(hist))
+	}
+	if hist[0].Kind != kindScheduled {
+		t.Errorf("legacy row: kind %q; want %q", hist[0].Kind, kindScheduled)
+	}
+
+	tests := []struct {
+		task string
+		due  time.Time
+		want bool
+	}{
+		{"reminder", time.Date(2026, 6, 20, 10, 0, 0, 0, loc), true},
+		{"reminder", julyDue, false}, // explicit run only
+		{"invitation", julyDue, true},
+	}
+	for _, tt := range tests {
+		if got := delivered(hist, "fmt", tt.task, tt.due); got != tt.want {
+			t.Errorf("%v %v: delivered %v; want %v", tt.task, tt.due.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// easterSunday - anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+}
+
+// holidayRegions - "de" and the states "de-xx" by ISO 3166-2;
+// federal holidays are always included
+var holidayRegions = map[string]bool{
+	"de":    true,
+	"de-bw": true, // Baden-Württemberg
+	"de-by": true, // Bayern
+	"de-be": true, // Berlin
+	"de-bb": true, // Brandenburg
+	"de-hb": true, // Bremen
+	"de-hh": true, // Hamburg
+	"de-he": true, // Hessen
+	"de-mv": true, // Mecklenburg-Vorpommern
+	"de-ni": true, // Niedersachsen
+	"de-nw": true, // Nordrhein-Westfalen
+	"de-rp": true, // Rheinland-Pfalz
+	"de-sl": true, // Saarland
+	"de-sn": true, // Sachsen
+	"de-st": true, // Sachsen-Anhalt
+	"de-sh": true, // Schleswig-Holstein
+	"de-th": true, // Thüringen
+}
+
+// holidays returns the public holidays of a year - 2006-01-02 => name;
+// region "de" - federal holidays; "de-bw" - plus Baden-Württemberg.
+// Holidays of single municipalities - Mariä Himmelfahrt in Bavaria,
+// Fronleichnam in parts of Saxony and Thuringia - are not included.
+func holidays(year int, region string) (map[string]string, error) {
+
+	region = strings.ToLower(region)
+	if !holidayRegions[region] {
+		return nil, fmt.Errorf("unknown holiday region %q", region)
+	}
+	state := strings.TrimPrefix(region, "de-")
+	in := func(states ...string) bool {
+		for _, st := range states {
+			if st == state {
+				return true
+			}
+		}
+		return false
+	}
+
+	hds := map[string]string{}
+	fixed := func(m time.Month, d int, name string) {
+		hds[time.Date(year, m, d, 0, 0, 0, 0, loc).Format("2006-01-02")] = name
+	}
+	easter := easterSunday(year)
+	relative := func(days int, name string) {
+		hds[easter.AddDate(0, 0, days).Format("2006-01-02")] = name
+	}
+
+	fixed(1, 1, "Neujahr")
+	relative(-2, "Karfreitag")
+	relative(1, "Ostermontag")
+	fixed(5, 1, "Tag der Arbeit")
+	relative(39, "Christi Himmelfahrt")
+	relative(50, "Pfingstmontag")
+	fixed(10, 3, "Tag der Deutschen Einheit")
+	fixed(12, 25, "1. Weihnachtstag")
+	fixed(12, 26, "2. Weihnachtstag")
+	if year == 2017 {
+		fixed(10, 31, "Reformationstag") // 500 years - nationwide
+	}
+
+	if in("bw", "by", "st") {
+		fixed(1, 6, "Heilige Drei Könige")
+	}
+	if in("be") && year >= 2019 || in("mv") && year >= 2023 {
+		fixed(3, 8, "Internationaler Frauentag")
+	}
+	if in("bb") {
+		relative(0, "Ostersonntag")
+		relative(49, "Pfingstsonntag")
+	}
+	if in("bw", "by", "he", "nw", "rp", "sl") {
+		relative(60, "Fronleichnam")
+	}
+	if in("sl") {
+		fixed(8, 15, "Mariä Himmelfahrt")
+	}
+	if in("th") && year >= 2019 {
+		fixed(9, 20, "Weltkindertag")
+	}
+	if in("bb", "mv", "sn", "st", "th") || in("hb", "hh", "ni", "sh") && year >= 2018 {
+		fixed(10, 31, "Reformationstag")
+	}
+	if in("bw", "by", "nw", "rp", "sl") {
+		fixed(11, 1, "Allerheiligen")
+	}
+	if in("sn") {
+		// Wednesday before 23 November
+		d := time.Date(year, 11, 22, 0, 0, 0, 0, loc)
+		for d.Weekday() != time.Wednesday {
+			d = d.AddDate(0, 0, -1)
+		}
+		hds[d.Format("2006-01-02")] = "Buß- und Bettag"
+	}
+
+	return hds, nil
+}
+
+// holidayName returns the name of the holiday at t - or empty string;
+// extra are additional dates 2006-01-02
+func holidayName(t time.Time, region string, extra []string) string {
+	key := t.In(loc).Format("2006-01-02")
+	for _, x := range extra {
+		if x == key {
+			return "custom"
+		}
+	}
+	hds, err := holidays(t.In(loc).Year(), region)
+	if err != nil {
+		return ""
+	}
+	return hds[key]
+}
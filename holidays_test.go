@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHolidays(t *testing.T) {
+
+	prevLoc := loc
+	defer func() { loc = prevLoc }()
+	loc = time.UTC
+
+	tests := []struct {
+		region string
+		date   string
+		want   string // empty - no holiday
+	}{
+		{"de", "2026-01-01", "Neujahr"},
+		{"de", "2026-04-03", "Karfreitag"},
+		{"de", "2026-04-06", "Ostermontag"},
+		{"de", "2026-05-14", "Christi Himmelfahrt"},
+		{"de", "2026-05-25", "Pfingstmontag"},
+		{"de", "2026-06-04", ""},
+		{"de", "2017-10-31", "Reformationstag"},
+		{"de", "2026-10-31", ""},
+		{"DE-BW", "2026-01-06", "Heilige Drei Könige"},
+		{"de-bw", "2026-06-04", "Fronleichnam"},
+		{"de-bw", "2026-11-01", "Allerheiligen"},
+		{"de-by", "2026-08-15", ""},
+		{"de-sl", "2026-08-15", "Mariä Himmelfahrt"},
+		{"de-be", "2026-03-08", "Internationaler Frauentag"},
+		{"de-be", "2018-03-08", ""},
+		{"de-mv", "2022-03-08", ""},
+		{"de-mv", "2023-03-08", "Internationaler Frauentag"},
+		{"de-th", "2026-09-20", "Weltkindertag"},
+		{"de-sn", "2026-11-18", "Buß- und Bettag"},
+		{"de-sn", "2027-11-17", "Buß- und Bettag"},
+		{"de-sn", "2026-10-31", "Reformationstag"},
+		{"de-ni", "2017-10-31", "Reformationstag"},
+		{"de-ni", "2026-10-31", "Reformationstag"},
+		{"de-nw", "2026-10-31", ""},
+		{"de-bb", "2026-04-05", "Ostersonntag"},
+		{"de-he", "2026-06-04", "Fronleichnam"},
+	}
+	for _, tt := range tests {
+		d, _ := time.Parse("2006-01-02", tt.date)
+		if got := holidayName(d, tt.region, nil); got != tt.want {
+			t.Errorf("%v %v: %q; want %q", tt.region, tt.date, got, tt.want)
+		}
+	}
+
+	if _, err := holidays(2026, "de-xx"); err == nil {
+		t.Errorf("unknown region de-xx: want error")
+	}
+}
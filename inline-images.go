@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/domodwyer/mailyak/v3"
+)
+
+// Inline attachments are embedded into HTML emails as multipart/related parts.
+// Templates reference them by label:
+//
+//	<img src="{{cid "img1.gif"}}">
+//
+// Labels with placeholders - {{Quarter}}, %v - may be referenced
+// as written in config.json or as formatted.
+// The Content-ID is generated from project, file name and sender domain.
+//
+// mailyak knows no multipart/related; its AttachInline writes a flat multipart/mixed
+// with the file name as Content-ID. Thus the message built by mailyak is restructured;
+// messages with inline images are sent by net/smtp, all others by mailyak.
+
+var cidRefRe = regexp.MustCompile(`cid:([^"'\s<>)]+)`)
+
+// attachmentLabel replaces the placeholders of the attachment label
+func attachmentLabel(att AttachmentT, rec Recipient, wv WaveT) string {
+	lbl := att.Label
+	// if attachment label contains placeholders, replace with wave data
+	if strings.Contains(lbl, "{{Quarter}}") {
+		lbl = strings.ReplaceAll(lbl, "{{Quarter}}", rec.Quarter)
+	}
+	if strings.Contains(lbl, "{{QuarterYear}}") {
+		lbl = strings.ReplaceAll(lbl, "{{QuarterYear}}", rec.QuarterYear)
+	}
+	if strings.Contains(lbl, "%v") {
+		lbl = fmt.Sprintf(lbl, wv.Year, int(wv.Month))
+	}
+	return lbl
+}
+
+// contentID is stable across recipients and runs
+func contentID(project string, att AttachmentT, lbl, domain string) string {
+	h := fnv.New32a()
+	h.Write([]byte(project + "/" + att.Filename))
+	lbl = strings.Map(func(r rune) rune {
+		if r < 128 && (r == '.' || r == '-' || r == '_' ||
+			'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, lbl)
+	return fmt.Sprintf("%v.%08x@%v", lbl, h.Sum32(), domain)
+}
+
+// senderAddress is the project sender or the task specific sender - as in composeEmail
+func senderAddress(project string, tsk TaskT) mail.Address {
+	from := mail.Address{}
+	if cfg.Projects[project].From != nil {
+		from = *cfg.Projects[project].From
+	}
+	if tsk.From != nil && tsk.From.Address != "" && tsk.From.Name != "" {
+		from = *tsk.From
+	}
+	return from
+}
+
+// inlineImages returns the content IDs of inline attachments
+// for an HTML task and the recipient language;
+// label - raw and formatted - => content ID
+func inlineImages(project string, tsk TaskT, rec Recipient, wv WaveT, lang string) map[string]string {
+	cids := map[string]string{}
+	if !tsk.HTML {
+		return cids
+	}
+	domain := "go-massmail"
+	if parts := strings.Split(senderAddress(project, tsk).Address, "@"); len(parts) == 2 {
+		domain = parts[1]
+	}
+	for _, att := range tsk.Attachments {
+		if att.Inline && att.Language == lang {
+			lbl := attachmentLabel(att, rec, wv)
+			cid := contentID(project, att, lbl, domain)
+			cids[att.Label] = cid
+			cids[lbl] = cid
+		}
+	}
+	return cids
+}
+
+// cidFunc is the template func {{cid "img1.gif"}};
+// the label extension may be omitted
+func cidFunc(cids map[string]string) func(string) (template.URL, error) {
+	return func(label string) (template.URL, error) {
+		if cid, ok := cids[label]; ok {
+			return template.URL("cid:" + cid), nil
+		}
+		for lbl, cid := range cids {
+			if strings.TrimSuffix(lbl, filepath.Ext(lbl)) == label {
+				return template.URL("cid:" + cid), nil
+			}
+		}
+		return "", fmt.Errorf("no inline attachment %q", label)
+	}
+}
+
+// checkCIDRefs returns an error for every cid: reference
+// without matching inline attachment
+func checkCIDRefs(body string, cids map[string]string) error {
+	known := map[string]bool{}
+	for _, cid := range cids {
+		known[cid] = true
+	}
+	missing := []string{}
+	for _, m := range cidRefRe.FindAllStringSubmatch(body, -1) {
+		if !known[m[1]] {
+			missing = append(missing, m[1])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("template references %v - no inline attachment; use {{cid \"label\"}}", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// withoutHeader removes a header field - including its continuation lines -
+// from a raw header block
+func withoutHeader(header []byte, name string) []byte {
+	out := &bytes.Buffer{}
+	skip := false
+	sc := bufio.NewScanner(bytes.NewReader(header))
+	sc.Buffer(make([]byte, 0, 64*1024), len(header)+1)
+	for sc.Scan() {
+		line := sc.Text()
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if !skip {
+				out.WriteString(line + "\r\n")
+			}
+			continue
+		}
+		key, _, _ := strings.Cut(line, ":")
+		skip = strings.EqualFold(strings.TrimSpace(key), name)
+		if !skip {
+			out.WriteString(line + "\r\n")
+		}
+	}
+	return out.Bytes()
+}
+
+// relateInline restructures the MIME message built by mailyak;
+// attachments referenced from the HTML body move into a multipart/related part,
+// together with the text bodies:
+//
+//	multipart/mixed
+//	  multipart/related
+//	    multipart/alternative
+//	    image/gif  - Content-ID, inline
+//	  application/pdf
+//
+// cids maps attachment labels to content IDs.
+// Inline attachments not referenced are left as regular attachments.
+// Returns the message unchanged - and false - without referenced inline attachments.
+func relateInline(raw []byte, cids map[string]string, html string) ([]byte, bool, error) {
+
+	referenced := map[string]string{} // label => content ID
+	for lbl, cid := range cids {
+		if strings.Contains(html, "cid:"+cid) {
+			referenced[lbl] = cid
+		}
+	}
+	if len(referenced) == 0 {
+		return raw, false, nil
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, false, err
+	}
+	if mediaType != "multipart/mixed" {
+		return nil, false, fmt.Errorf("MIME message is %v - not multipart/mixed", mediaType)
+	}
+	idx := bytes.Index(raw, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return nil, false, fmt.Errorf("MIME message without header end")
+	}
+	header := withoutHeader(raw[:idx+2], "Content-Type")
+
+	type partT struct {
+		header textproto.MIMEHeader
+		body   []byte
+	}
+	var alternative partT
+	related, mixed := []partT{}, []partT{}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		bts, err := io.ReadAll(p)
+		if err != nil {
+			return nil, false, err
+		}
+		pt := partT{p.Header, bts}
+		if strings.HasPrefix(p.Header.Get("Content-Type"), "multipart/alternative") {
+			alternative = pt
+			continue
+		}
+		_, dispParams, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+		if cid, ok := referenced[dispParams["filename"]]; ok {
+			pt.header.Set("Content-ID", "<"+cid+">")
+			pt.header.Set("Content-Disposition", fmt.Sprintf("inline;\r\n\tfilename=%q", dispParams["filename"]))
+			related = append(related, pt)
+			continue
+		}
+		mixed = append(mixed, pt)
+	}
+	if alternative.header == nil {
+		return nil, false, fmt.Errorf("MIME message without text bodies")
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(header)
+
+	mw := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "Content-Type: multipart/mixed;\r\n\tboundary=\"%s\"; charset=UTF-8\r\n\r\n", mw.Boundary())
+
+	rb := &bytes.Buffer{}
+	rw := multipart.NewWriter(rb)
+	for _, pt := range append([]partT{alternative}, related...) {
+		w, err := rw.CreatePart(pt.header)
+		if err != nil {
+			return nil, false, err
+		}
+		w.Write(pt.body)
+	}
+	rw.Close()
+
+	w, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/related;\r\n\ttype=\"multipart/alternative\";\r\n\tboundary=\"%s\"", rw.Boundary())},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	w.Write(rb.Bytes())
+
+	for _, pt := range mixed {
+		w, err := mw.CreatePart(pt.header)
+		if err != nil {
+			return nil, false, err
+		}
+		w.Write(pt.body)
+	}
+	mw.Close()
+
+	return buf.Bytes(), true, nil
+}
+
+// mimeMessage returns the complete message - for sending and for preview;
+// related is true, if inline attachments were moved into a multipart/related part
+func mimeMessage(m *mailyak.MailYak, cids map[string]string) (msg []byte, related bool, err error) {
+	buf, err := m.MimeBuf()
+	if err != nil {
+		return nil, false, err
+	}
+	return relateInline(buf.Bytes(), cids, m.HTML().String())
+}
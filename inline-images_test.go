@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/domodwyer/mailyak/v3"
+)
+
+func TestRelateInline(t *testing.T) {
+
+	prevCfg := cfg
+	defer func() { cfg = prevCfg }()
+	cfg = configT{Projects: map[string]ProjectT{"p": {From: &mail.Address{Address: "survey@zew.de"}}}}
+
+	tsk := TaskT{
+		HTML: true,
+		Attachments: []AttachmentT{
+			{Filename: "logo.gif", Label: "logo.gif", Language: "de", Inline: true},
+			{Filename: "chart-%v-%02d.gif", Label: "chart-%v-%02d.gif", Language: "de", Inline: true},
+			{Filename: "unused.gif", Label: "unused.gif", Language: "de", Inline: true},
+			{Filename: "report.pdf", Label: "report.pdf", Language: "de"},
+		},
+	}
+	wv := WaveT{Year: 2026, Month: 7}
+	cids := inlineImages("p", tsk, Recipient{}, wv, "de")
+
+	logo, err := cidFunc(cids)("logo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart, err := cidFunc(cids)("chart-2026-07.gif") // formatted label
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(logo), "@zew.de") || logo == chart {
+		t.Fatalf("content IDs %q %q", logo, chart)
+	}
+
+	html := `<img src="` + string(logo) + `"><img src="` + string(chart) + `">`
+	if err := checkCIDRefs(html, cids); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCIDRefs(html+`<img src="cid:logo.gif">`, cids); err == nil {
+		t.Error("unknown cid:logo.gif not reported")
+	}
+
+	m := mailyak.New("localhost:25", nil)
+	m.From("survey@zew.de")
+	m.To("a@example.com")
+	m.Subject("Test")
+	m.AddHeader("X-Mailer", "go-massmail")
+	m.Plain().Set("plain")
+	m.HTML().Set(html)
+	for _, lbl := range []string{"logo.gif", "chart-2026-07.gif", "unused.gif"} {
+		m.Attach(lbl, strings.NewReader("GIF89a"))
+	}
+	m.Attach("report.pdf", strings.NewReader("%PDF-1.4"))
+	buf, err := m.MimeBuf()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Content-Type not as last header - must not matter
+	raw := buf.Bytes()
+	hdrEnd := bytes.Index(raw, []byte("\r\n\r\n")) + 2
+	reordered := append(withoutHeader(raw[:hdrEnd], "X-Mailer"), "X-Mailer: go-massmail\r\n"...)
+	reordered = append(reordered, raw[hdrEnd:]...)
+
+	msg, related, err := relateInline(reordered, cids, html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !related {
+		t.Fatal("not related")
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []string{"From", "To", "Subject", "X-Mailer"} {
+		if parsed.Header.Get(h) == "" {
+			t.Errorf("header %v lost", h)
+		}
+	}
+	if n := strings.Count(string(msg[:bytes.Index(msg, []byte("\r\n\r\n"))]), "Content-Type:"); n != 1 {
+		t.Errorf("%v Content-Type headers", n)
+	}
+
+	// multipart/mixed > multipart/related > alternative, logo, chart;  unused, report
+	structure := []string{}
+	var walk func(ct string, body io.Reader, depth int)
+	walk = func(ct string, body io.Reader, depth int) {
+		mt, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		structure = append(structure, strings.Repeat(" ", depth)+mt)
+		if !strings.HasPrefix(mt, "multipart/") {
+			return
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cid := p.Header.Get("Content-ID"); strings.HasSuffix(cid, "@zew.de>") {
+				structure = append(structure, strings.Repeat(" ", depth+1)+"cid")
+			}
+			walk(p.Header.Get("Content-Type"), p, depth+1)
+		}
+	}
+	walk(parsed.Header.Get("Content-Type"), parsed.Body, 0)
+
+	want := strings.Join([]string{
+		"multipart/mixed",
+		" multipart/related",
+		"  multipart/alternative",
+		"   text/plain",
+		"   text/html",
+		"  cid",
+		"  image/gif",
+		"  cid",
+		"  image/gif",
+		" image/gif",
+		" application/pdf",
+	}, "\n")
+	if got := strings.Join(structure, "\n"); got != want {
+		t.Errorf("structure\n%v\nwant\n%v", got, want)
+	}
+
+	// without references, the message is unchanged
+	plain, related, err := relateInline(buf.Bytes(), cids, "<p>no images</p>")
+	if err != nil || related || !bytes.Equal(plain, buf.Bytes()) {
+		t.Errorf("message without references changed - related %v, err %v", related, err)
+	}
+}
+
+func TestWithoutHeader(t *testing.T) {
+	header := "From: a@example.com\r\n" +
+		"Content-Type: multipart/mixed;\r\n\tboundary=\"xyz\"; charset=UTF-8\r\n" +
+		"Subject: Test\r\n"
+	got := string(withoutHeader([]byte(header), "content-type"))
+	want := "From: a@example.com\r\nSubject: Test\r\n"
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
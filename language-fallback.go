@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"sort"
+)
+
+// fallbackLanguage returns the language to use for a recipient language;
+// unsupported languages - i.e. "fr" or empty - fall back
+// to the first supported language of LanguageFallback or DefaultLanguage.
+// Without configured Languages every language is accepted.
+func fallbackLanguage(project, lang string) (string, bool) {
+
+	prj := cfg.Projects[project]
+	if len(prj.Languages) == 0 || slices.Contains(prj.Languages, lang) {
+		return lang, false
+	}
+
+	order := prj.LanguageFallback
+	if len(order) == 0 && prj.DefaultLanguage != "" {
+		order = []string{prj.DefaultLanguage}
+	}
+	for _, fb := range order {
+		if slices.Contains(prj.Languages, fb) {
+			return fb, true
+		}
+	}
+
+	return lang, false
+}
+
+// logLanguageFallbacks shows how many recipients fall back - in preflight
+func logLanguageFallbacks(recs []*Recipient) {
+	cnts := map[string]int{}
+	for _, rec := range recs {
+		if rec.langFallback != "" {
+			cnts[rec.langFallback]++
+		}
+	}
+	keys := make([]string, 0, len(cnts))
+	for key := range cnts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		log.Printf("  language fallback %-14v %4d recipient(s)", key, cnts[key])
+	}
+}
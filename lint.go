@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lintFinding is a template problem found by lintAll()
+type lintFinding struct {
+	Project  string
+	Task     string
+	Language string
+	Msg      string
+}
+
+func (lf lintFinding) String() string {
+	return fmt.Sprintf("%v-%-22v %2v  %v", lf.Project, lf.Task, lf.Language, lf.Msg)
+}
+
+var (
+	// language code in template names: partial-de-footer.html, partial-invite-footer-de.md
+	lintLangCodeRe = regexp.MustCompile(`-([a-z]{2})[-.]`)
+	lintLinkRe     = regexp.MustCompile(`(?:href|src)\s*=\s*"([^"]*)"|https?://[^\s"'<>]+`)
+)
+
+// lintAll checks the templates of every project, task and language in config.json
+// against a synthetic and a real sample recipient.
+// Recipient CSV files are read locally - no download.
+// Links are checked syntactically - no HTTP requests.
+// Returns the number of findings.
+func lintAll() int {
+
+	findings := []lintFinding{}
+
+	projects := make([]string, 0, len(cfg.Tasks))
+	for project := range cfg.Tasks {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	for _, project := range projects {
+		wvs := cfg.Waves[project]
+		if len(wvs) < 1 {
+			findings = append(findings, lintFinding{project, "-", "", "no wave"})
+			continue
+		}
+		for _, tsk := range cfg.Tasks[project] {
+			wv, _, err := selectWave(project, "", tsk, taskTime(tsk))
+			if err != nil {
+				findings = append(findings, lintFinding{project, tsk.Name, "", err.Error()})
+				continue
+			}
+			if err := checkWave(project, wv, taskTime(tsk)); err != nil {
+				findings = append(findings, lintFinding{project, tsk.Name, "", err.Error()})
+			}
+			for _, vtsk := range variantTasks(tsk) {
+				findings = append(findings, lintTask(project, wv, vtsk)...)
+			}
+		}
+	}
+
+	for _, lf := range findings {
+		log.Print(lf)
+	}
+	log.Printf("lint: %v finding(s)", len(findings))
+
+	return len(findings)
+}
+
+// lintTask checks all languages of a single task
+func lintTask(project string, wv WaveT, tsk TaskT) []lintFinding {
+
+	findings := []lintFinding{}
+	label := tsk.Name
+	if tsk.variant != "" {
+		label += "/" + tsk.variant
+	}
+	add := func(lang, format string, args ...any) {
+		findings = append(findings, lintFinding{project, label, lang, fmt.Sprintf(format, args...)})
+	}
+
+	templateFile := tsk.Name
+	if tsk.TemplateName != "" {
+		templateFile = tsk.TemplateName
+	}
+	ext := "md"
+	if tsk.HTML {
+		ext = "html"
+	}
+
+	//
+	// recipients from local CSV - if present
+	recs := []*Recipient{}
+	columns := []string{}
+	fn := filepath.Join(".", "csv", project, tsk.Name+".csv")
+	if inFile, err := os.Open(fn); err == nil {
+		recs, err = parseCSV(inFile, project, wv, tsk)
+		inFile.Close()
+		if err != nil && !errors.Is(err, errStaleClosingDate) {
+			add("", "%v", err)
+		}
+		if len(recs) > 0 {
+			for col := range recs[0].Fields {
+				columns = append(columns, col)
+			}
+		}
+	}
+
+	//
+	// languages - from recipients, otherwise from template files
+	langs := map[string]bool{}
+	for _, rec := range recs {
+		langs[rec.Language] = true
+	}
+	if len(recs) == 0 {
+		for _, lang := range templateLanguages(project, tsk) {
+			langs[lang] = true
+		}
+		if len(langs) == 0 {
+			add("", "no template %v-*.%v", templateFile, ext)
+		}
+	}
+
+	sortedLangs := make([]string, 0, len(langs))
+	for lang := range langs {
+		sortedLangs = append(sortedLangs, lang)
+	}
+	sort.Strings(sortedLangs)
+
+	for _, lang := range sortedLangs {
+
+		fn, err := resolveTemplate(project, wv, tsk, lang)
+		if err != nil {
+			add(lang, "%v", err)
+			continue
+		}
+		pth, _ := templatePath(project, fn)
+		raw, err := os.ReadFile(pth)
+		if err != nil {
+			add(lang, "%v", err)
+			continue
+		}
+
+		//
+		// raw file checks - main template and includes
+		includes, err := templateIncludes(project, fn)
+		if err != nil {
+			add(lang, "%v", err)
+		}
+		for _, pthRaw := range append([]string{pth}, includes...) {
+			bts, err := os.ReadFile(pthRaw)
+			if err != nil {
+				add(lang, "%v", err)
+				continue
+			}
+			if strings.Contains(string(bts), "\r\n") {
+				add(lang, "%v contains \\r\\n line endings", filepath.Base(pthRaw))
+			}
+		}
+
+		for _, ref := range tplRefRe.FindAllStringSubmatch(string(raw), -1) {
+			matches := lintLangCodeRe.FindAllStringSubmatch(ref[1], -1)
+			if len(matches) == 0 {
+				continue
+			}
+			sameLang := false
+			for _, m := range matches {
+				if m[1] == lang {
+					sameLang = true
+				}
+			}
+			if !sameLang {
+				add(lang, "%v includes partial %q of another language", fn, ref[1])
+			}
+		}
+
+		//
+		// execution
+		samples := map[string]Recipient{}
+
+		syn := syntheticRecipient(lang, columns)
+		syn.SetDerived(project, &wv, &tsk) // error about stale closing dates irrelevant here
+		samples["synthetic"] = syn
+
+		for _, rec := range recs {
+			if rec.Language == lang && !strings.Contains(rec.NoMail, "noMail") {
+				samples[fmt.Sprintf("recipient %v", rec.ID)] = *rec
+				break
+			}
+		}
+
+		for sampleName, rec := range samples {
+			rec.missingFields = map[string]bool{}
+			subject, body, err := renderText(rec, project, wv, tsk, lang)
+			if err != nil {
+				add(lang, "%v: %v", sampleName, err)
+				continue
+			}
+			for _, msg := range lintOutput(subject, body) {
+				add(lang, "%v: %v", sampleName, msg)
+			}
+			for col := range rec.missingFields {
+				add(lang, "%v: missing field %q", sampleName, col)
+			}
+		}
+
+	}
+
+	return findings
+}
+
+// templateLanguages returns the languages, for which the task has a template file
+func templateLanguages(project string, tsk TaskT) []string {
+
+	templateFile := tsk.Name
+	if tsk.TemplateName != "" {
+		templateFile = tsk.TemplateName
+	}
+	ext := "md"
+	if tsk.HTML {
+		ext = "html"
+	}
+
+	langs := []string{}
+	seen := map[string]bool{}
+	for _, dir := range []string{filepath.Join(".", "tpl", project), tplSharedDir} {
+		candidates, _ := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%v-*.%v", templateFile, ext)))
+		for _, cand := range candidates {
+			lang := strings.TrimSuffix(filepath.Base(cand), "."+ext)
+			lang = strings.TrimPrefix(lang, templateFile+"-")
+			if len(lang) == 2 && !seen[lang] {
+				seen[lang] = true
+				langs = append(langs, lang)
+			}
+		}
+	}
+	return langs
+}
+
+// syntheticRecipient has all fields filled
+func syntheticRecipient(lang string, columns []string) Recipient {
+	rec := Recipient{
+		ID:        "99999",
+		Email:     "lint@example.com",
+		Sex:       1,
+		Title:     "Dr.",
+		Firstname: "Erika",
+		Lastname:  "Mustermann",
+		Link:      template.HTML("https://example.com/survey?u=99999"),
+		Language:  lang,
+		Fields:    map[string]string{},
+	}
+	for _, col := range columns {
+		rec.Fields[col] = "lint-" + col
+	}
+	return rec
+}
+
+// lintOutput checks the executed template
+func lintOutput(subject, body string) []string {
+
+	msgs := []string{}
+
+	if strings.TrimSpace(subject) == "" {
+		msgs = append(msgs, "empty subject - first line of template")
+	}
+
+	full := subject + "\n" + body
+	if strings.Contains(full, "{{") {
+		msgs = append(msgs, "unresolved {{ in output")
+	}
+	if strings.Contains(full, "<no value>") {
+		msgs = append(msgs, "<no value> in output")
+	}
+
+	for _, m := range lintLinkRe.FindAllStringSubmatch(full, -1) {
+		lnk := m[0]
+		if m[1] != "" || strings.HasPrefix(m[0], "href") || strings.HasPrefix(m[0], "src") {
+			lnk = m[1]
+		}
+		if msg := lintLink(lnk); msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return msgs
+}
+
+// lintLink returns a message for a broken link - or empty string
+func lintLink(lnk string) string {
+
+	lnk = strings.TrimSpace(lnk)
+	lnk = strings.ReplaceAll(lnk, "&amp;", "&") // HTML escaped
+
+	if lnk == "" {
+		return "empty link"
+	}
+	if strings.HasPrefix(lnk, "#") || strings.HasPrefix(lnk, "cid:") {
+		return ""
+	}
+	if strings.HasPrefix(lnk, "mailto:") {
+		addr := strings.SplitN(strings.TrimPrefix(lnk, "mailto:"), "?", 2)[0]
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Sprintf("broken mailto link %q", lnk)
+		}
+		return ""
+	}
+
+	u, err := url.Parse(lnk)
+	if err != nil {
+		return fmt.Sprintf("broken link %q - %v", lnk, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Sprintf("link without http(s) scheme %q", lnk)
+	}
+	if !strings.Contains(u.Host, ".") {
+		return fmt.Sprintf("link without valid host %q", lnk)
+	}
+	return ""
+}
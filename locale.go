@@ -0,0 +1,228 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Weekday and month names, date and number patterns per language
+// are read from locale/[lang].json - compiled into the binary.
+// Files in ./locale/ on disk take precedence;
+// additional languages only require another file.
+
+//go:embed locale/*.json
+var localeEmbedded embed.FS
+
+// localeDir is read at runtime - overriding the compiled-in files
+var localeDir = filepath.Join(".", "locale")
+
+// localeT is the content of a locale file
+type localeT struct {
+	Weekdays []string `json:"weekdays"` // Sunday first
+	Months   []string `json:"months"`   // January first
+
+	// placeholders {weekday} {day} {ordinal} {month} {year} {dd} {mm}
+	DateLong  string `json:"date_long"`  // Friday, 11th November 2022
+	DateShort string `json:"date_short"` // 11/11/2022
+	MonthYear string `json:"month_year"` // November 2022
+
+	// ordinal suffix for the day of month;
+	// exact match first, then last digit, then default
+	Ordinals struct {
+		Exact     map[string]string `json:"exact,omitempty"`
+		LastDigit map[string]string `json:"last_digit,omitempty"`
+		Default   string            `json:"default,omitempty"`
+	} `json:"ordinals"`
+
+	DecimalSep string `json:"decimal_sep"`
+	GroupSep   string `json:"group_sep"`
+}
+
+var locales map[string]localeT
+var localesOnce sync.Once
+
+// loadLocales reads the embedded locale files, then those on disk
+func loadLocales() {
+
+	locales = map[string]localeT{}
+
+	read := func(fsys fs.FS, src string) {
+		fns, _ := fs.Glob(fsys, "*.json")
+		for _, fn := range fns {
+			bts, err := fs.ReadFile(fsys, fn)
+			if err != nil {
+				log.Printf("locale %v/%v: %v", src, fn, err)
+				continue
+			}
+			l := localeT{}
+			if err := json.Unmarshal(bts, &l); err != nil {
+				log.Printf("locale %v/%v: %v", src, fn, err)
+				continue
+			}
+			if len(l.Weekdays) != 7 || len(l.Months) != 12 {
+				log.Printf("locale %v/%v: requires 7 weekdays and 12 months", src, fn)
+				continue
+			}
+			locales[strings.TrimSuffix(fn, ".json")] = l
+		}
+	}
+
+	sub, err := fs.Sub(localeEmbedded, "locale")
+	if err != nil {
+		log.Fatalf("embedded locales: %v", err)
+	}
+	read(sub, "embedded")
+
+	if fi, err := os.Stat(localeDir); err == nil && fi.IsDir() {
+		read(os.DirFS(localeDir), localeDir)
+	}
+}
+
+// getLocale returns the locale for lang; English for unknown languages
+func getLocale(lang string) localeT {
+	localesOnce.Do(loadLocales)
+	if l, ok := locales[lang]; ok {
+		return l
+	}
+	return locales["en"]
+}
+
+func (l localeT) ordinal(day int) string {
+	d := strconv.Itoa(day)
+	if sfx, ok := l.Ordinals.Exact[d]; ok {
+		return sfx
+	}
+	if sfx, ok := l.Ordinals.LastDigit[d[len(d)-1:]]; ok {
+		return sfx
+	}
+	return l.Ordinals.Default
+}
+
+func (l localeT) format(pattern string, dt time.Time) string {
+	return strings.NewReplacer(
+		"{weekday}", l.Weekdays[dt.Weekday()],
+		"{day}", strconv.Itoa(dt.Day()),
+		"{ordinal}", l.ordinal(dt.Day()),
+		"{month}", l.Months[dt.Month()-1],
+		"{year}", strconv.Itoa(dt.Year()),
+		"{dd}", fmt.Sprintf("%02d", dt.Day()),
+		"{mm}", fmt.Sprintf("%02d", int(dt.Month())),
+	).Replace(pattern)
+}
+
+// formatDate - Friday, 11th November 2022 - Freitag, 11. November 2022,
+func formatDate(dt time.Time, lang string) string {
+	l := getLocale(lang)
+	return l.format(l.DateLong, dt)
+}
+
+// formatDateShort - 11/11/2022 - 11.11.2022
+func formatDateShort(dt time.Time, lang string) string {
+	l := getLocale(lang)
+	return l.format(l.DateShort, dt)
+}
+
+// formatMonthYear - November 2022
+func formatMonthYear(year int, month time.Month, lang string) string {
+	l := getLocale(lang)
+	return l.format(l.MonthYear, time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// monthName maps 1 to January, 12 to December
+func monthName(month int, lang string) string {
+	if month < 1 || month > 12 {
+		return fmt.Sprintf("error_unknown_month_idx__%v", month)
+	}
+	return getLocale(lang).Months[month-1]
+}
+
+// formatNumber - 1,234.5 - 1.234,5
+func formatNumber(v float64, decimals int, lang string) string {
+
+	l := getLocale(lang)
+
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	sb := &strings.Builder{}
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		sb.WriteString("-")
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteString(l.GroupSep)
+		}
+		sb.WriteRune(r)
+	}
+	if fracPart != "" {
+		sb.WriteString(l.DecimalSep)
+		sb.WriteString(fracPart)
+	}
+	return sb.String()
+}
+
+// toTime accepts time.Time or strings 2006-01-02 and 2006-01-02T15:04
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", time.RFC3339} {
+			if dt, err := time.ParseInLocation(layout, t, loc); err == nil {
+				return dt, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse date %q", t)
+	}
+	return time.Time{}, fmt.Errorf("cannot format %T as date", v)
+}
+
+// toFloat accepts numbers and numeric strings - i.e. CSV columns
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	}
+	return 0, fmt.Errorf("cannot format %T as number", v)
+}
+
+// localeFuncs are template funcs for the recipient language
+//
+//	{{date "2025-11-14"}}    {{dateShort (.Field "deadline")}}
+//	{{month 11}}             {{number (.Field "amount") 2}}
+func localeFuncs(lang string) template.FuncMap {
+	return template.FuncMap{
+		"date": func(v any) (string, error) {
+			dt, err := toTime(v)
+			return formatDate(dt, lang), err
+		},
+		"dateShort": func(v any) (string, error) {
+			dt, err := toTime(v)
+			return formatDateShort(dt, lang), err
+		},
+		"month": func(m int) string {
+			return monthName(m, lang)
+		},
+		"number": func(v any, decimals int) (string, error) {
+			f, err := toFloat(v)
+			return formatNumber(f, decimals, lang), err
+		},
+	}
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
-	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
 )
 
 // RegistrationFMTEnH shows a registraton form for the FMT
@@ -13,9 +21,274 @@ func RegistrationFMTEnH(w http.ResponseWriter, r *http.Request) {
 	iterTasks()
 }
 
-func main() {
-	log.SetFlags(log.Lshortfile | log.Ltime)
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/", nil)
-	RegistrationFMTEnH(w, req)
+// tplWatchDir is polled for changes by the preview server
+var tplWatchDir = filepath.Join(".", "tpl")
+
+// tplFingerprint changes whenever a file under tpl/ is added, removed or modified
+func tplFingerprint() string {
+	cnt := 0
+	latest := time.Time{}
+	filepath.WalkDir(tplWatchDir, func(pth string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		cnt++
+		if fi, err := d.Info(); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+		return nil
+	})
+	return fmt.Sprintf("%d-%d", cnt, latest.UnixNano())
+}
+
+// localRecipients reads the recipient CSV from disk - no download
+func localRecipients(project string, wv WaveT, tsk TaskT) ([]*Recipient, error) {
+	fn := filepath.Join(".", "csv", project, tsk.Name+".csv")
+	inFile, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+	recs, err := parseCSV(inFile, project, wv, tsk)
+	if err != nil && !errors.Is(err, errStaleClosingDate) {
+		return nil, err
+	}
+	return recs, nil
 }
+
+// IndexH lists projects, tasks and waves
+func IndexH(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	type taskLinkT struct {
+		Name        string
+		Description string
+		Waves       []string
+	}
+	type projectLinkT struct {
+		Name  string
+		Tasks []taskLinkT
+	}
+
+	projects := []projectLinkT{}
+	for project := range cfg.Tasks {
+		pl := projectLinkT{Name: project}
+		waveKeys := []string{}
+		for _, wv := range cfg.Waves[project] {
+			waveKeys = append(waveKeys, waveKey(wv))
+		}
+		// latest first
+		sort.Sort(sort.Reverse(sort.StringSlice(waveKeys)))
+		for _, tsk := range cfg.Tasks[project] {
+			pl.Tasks = append(pl.Tasks, taskLinkT{tsk.Name, tsk.Description, waveKeys})
+		}
+		projects = append(projects, pl)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+
+	err := serveIndexTpl.Execute(w, map[string]any{
+		"Projects":    projects,
+		"Fingerprint": tplFingerprint(),
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// RenderH renders the task template for a single recipient row
+func RenderH(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	project := r.URL.Query().Get("project")
+	waveKey := r.URL.Query().Get("wave")
+	row, _ := strconv.Atoi(r.URL.Query().Get("row"))
+
+	data := map[string]any{
+		"Project":     project,
+		"Wave":        waveKey,
+		"Row":         row,
+		"Fingerprint": tplFingerprint(),
+	}
+	errs := []string{}
+
+	tsk, err := taskByName(project, r.URL.Query().Get("task"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	data["Task"] = tsk.Name
+	wv, _, err := selectWave(project, waveKey, tsk, taskTime(tsk))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	recs, err := localRecipients(project, wv, tsk)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("no recipients - using synthetic recipients: %v", err))
+		recs = nil
+		for _, lang := range templateLanguages(project, tsk) {
+			syn := syntheticRecipient(lang, nil)
+			syn.SetDerived(project, &wv, &tsk)
+			recs = append(recs, &syn)
+		}
+		if len(recs) == 0 {
+			http.Error(w, fmt.Sprintf("no template for %v - %v", project, tsk.Name), http.StatusNotFound)
+			return
+		}
+	}
+	if row < 0 || row >= len(recs) {
+		row = 0
+	}
+	data["Row"] = row
+	data["Recipients"] = recs
+
+	rec := *recs[row]
+	pv := previewT{Recipient: rec.String()}
+	raw, err := renderMime(project, rec, wv, tsk)
+	if err == nil {
+		pv, err = parseMessage(raw)
+	}
+	if err != nil {
+		errs = append(errs, err.Error())
+		// at least show the bodies - i.e. despite stale attachments
+		subj, body, err := renderText(rec, project, wv, tsk, rec.Language)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		pv.Subject = subj
+		pv.Plain = body
+		if tsk.HTML {
+			pv.HTML = body
+		}
+	}
+	data["Preview"] = pv
+	data["Errs"] = errs
+
+	if err := serveRenderTpl.Execute(w, data); err != nil {
+		log.Print(err)
+	}
+}
+
+// FingerprintH is polled by the pages to reload on template changes
+func FingerprintH(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, tplFingerprint())
+}
+
+// serve starts the local preview server;
+// templates are read from disk on every request
+func serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", IndexH)
+	mux.HandleFunc("/render", RenderH)
+	mux.HandleFunc("/fingerprint", FingerprintH)
+	log.Printf("preview server at http://%v/", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+const serveReloadJS = `
+<script>
+	// reload on changes under tpl/
+	setInterval(function() {
+		fetch("/fingerprint").then(r => r.text()).then(function(fp) {
+			if (fp !== "{{.Fingerprint}}") { location.reload(); }
+		});
+	}, 1000);
+</script>
+`
+
+const serveCSS = `
+<style>
+* { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; }
+body { margin: 1rem; }
+table { font-size: 80%; border-collapse: collapse; }
+td { vertical-align: top; padding: 0.1rem 0.4rem; border-bottom: 1px solid #ddd; word-break: break-all; }
+iframe { width: 100%; height: 40rem; border: 1px solid #ddd; }
+pre { white-space: pre-wrap; font-family: monospace; font-size: 85%; }
+.err { color: #c00; }
+.tabs > input { display: none; }
+.tabs > label { display: inline-block; padding: 0.3rem 1rem; border: 1px solid #ddd; cursor: pointer; }
+.tabs > input:checked + label { background: #ddd; }
+.tab { display: none; }
+#t-html:checked ~ .tab-html, #t-text:checked ~ .tab-text, #t-head:checked ~ .tab-head { display: block; }
+</style>
+`
+
+var serveIndexTpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>go-massmail preview</title>
+` + serveCSS + `
+</head>
+<body>
+<h1>go-massmail preview</h1>
+{{range .Projects}}
+	<h2>{{.Name}}</h2>
+	<table>
+	{{$project := .Name}}
+	{{range .Tasks}}
+		<tr>
+			<td>{{.Name}}</td>
+			<td>{{.Description}}</td>
+			<td>
+			{{$task := .Name}}
+			{{range .Waves}}<a href="/render?project={{$project}}&task={{$task}}&wave={{.}}">{{.}}</a> {{end}}
+			</td>
+		</tr>
+	{{end}}
+	</table>
+{{end}}
+` + serveReloadJS + `
+</body>
+</html>
+`))
+
+var serveRenderTpl = template.Must(template.New("render").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Project}} - {{.Task}} - {{.Wave}}</title>
+` + serveCSS + `
+</head>
+<body>
+<p><a href="/">index</a></p>
+<h1>{{.Project}} - {{.Task}} - {{.Wave}}</h1>
+
+<form method="GET" action="/render">
+	<input type="hidden" name="project" value="{{.Project}}">
+	<input type="hidden" name="task"    value="{{.Task}}">
+	<input type="hidden" name="wave"    value="{{.Wave}}">
+	<select name="row" onchange="this.form.submit()">
+	{{$row := .Row}}
+	{{range $idx, $rec := .Recipients}}
+		<option value="{{$idx}}" {{if eq $idx $row}}selected{{end}}>{{$rec.ID}} - {{$rec.Language}} {{$rec.Variant}} - {{$rec.Email}} {{$rec.NoMail}}</option>
+	{{end}}
+	</select>
+</form>
+
+{{range .Errs}}<p class="err">{{.}}</p>{{end}}
+
+{{with .Preview}}
+<h2>{{.Subject}}</h2>
+<p>Attachments: {{range .Attachments}}<br>{{.}}{{else}}none{{end}}</p>
+<div class="tabs">
+	<input type="radio" name="tabs" id="t-html" {{if .HTML}}checked{{end}}><label for="t-html">HTML</label>
+	<input type="radio" name="tabs" id="t-text" {{if not .HTML}}checked{{end}}><label for="t-text">Text</label>
+	<input type="radio" name="tabs" id="t-head"><label for="t-head">Headers</label>
+	<div class="tab tab-html">{{if .HTML}}<iframe sandbox srcdoc="{{.HTML}}"></iframe>{{else}}<p>no HTML body</p>{{end}}</div>
+	<div class="tab tab-text"><pre>{{.Plain}}</pre></div>
+	<div class="tab tab-head">
+		<table>
+		{{range .Headers}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+		{{end}}
+		</table>
+	</div>
+</div>
+{{end}}
+` + serveReloadJS + `
+</body>
+</html>
+`))
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"mime"
 	"net"
 	"net/mail"
+	"net/smtp"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -19,7 +21,7 @@ import (
 
 	"golang.org/x/term"
 
-	"github.com/domodwyer/mailyak"
+	"github.com/domodwyer/mailyak/v3"
 	"github.com/gocarina/gocsv"
 	"github.com/jackpal/gateway"
 )
@@ -58,6 +60,23 @@ type Recipient struct {
 	LinkHelp        string `csv:"-"` // LinkHlp()
 
 	IFG_Reference string `csv:"ifg_reference"`
+
+	Variant string `csv:"-"` // A/B variant - assignVariant()
+
+	// all CSV columns, including those without struct field; see Field()
+	Fields map[string]string `csv:"-"`
+
+	// collecting unknown column names requested by Field() - set by lint
+	missingFields map[string]bool
+
+	// set by rule keep_anrede; see rules.go
+	keepAnrede bool
+
+	// rule applied by salutation(); i.e. "fallback"
+	salutationKind string
+
+	// requested and effective language, i.e. "fr" => "en"; see fallbackLanguage()
+	langFallback string
 }
 
 func (rec Recipient) String() string {
@@ -126,56 +145,6 @@ func isInternalGateway() bool {
 	return false
 }
 
-func formatDate(dt time.Time, lang string) string {
-
-	if lang == "en" {
-		d := dt.Day()
-		// https://www.business-spotlight.de/sprachratgeber-business-englisch-lernen/englische-datums-und-zeitangaben
-		format := "Monday, 2th January 2006"
-		if d%10 == 1 {
-			format = "Monday, 2st January 2006"
-		} else if d%10 == 2 {
-			format = "Monday, 2nd January 2006"
-		} else if d%10 == 3 {
-			format = "Monday, 2rd January 2006"
-		}
-		ret := dt.Format(format)
-
-		return ret
-	}
-
-	if lang == "de" {
-
-		ret := dt.Format("Monday, 2. January 2006")
-
-		m := dt.Month()
-		w := dt.Weekday()
-		ret =
-			strings.Replace(
-				ret,
-				MonthByInt(int(m), "en"),
-				MonthByInt(int(m), "de"),
-				-1,
-			)
-
-		ret =
-			strings.Replace(
-				ret,
-				WeekdayByInt(int(w), "en"),
-				WeekdayByInt(int(w), "de"),
-				-1,
-			)
-
-		ret += "," // add apposition comma
-		return ret
-	}
-
-	// any other
-	ret := dt.Format("Monday, 2th January 2006")
-	return ret
-
-}
-
 func formatDuration(d time.Duration) string {
 	d = d.Round(time.Second)
 
@@ -385,82 +354,28 @@ func (rec *Recipient) CheckEmail() error {
 
 }
 
-func (rec *Recipient) SetDerived(project string, wv *WaveT, tsk *TaskT) {
+var errStaleClosingDate = errors.New("closing date too old")
 
-	if project == "copol" {
-		// implicitly all English - but we are too lazy to change the CSV
-		rec.Language = "en"
-	}
+// SetDerived computes salutation, formatted dates, links etc.
+// An error is returned for closing dates, which are too old;
+// the derived fields are set nevertheless.
+func (rec *Recipient) SetDerived(project string, wv *WaveT, tsk *TaskT) error {
 
-	if project == "muni" {
-		// implicitly all German
-		rec.Language = "de"
+	// language defaults, exclusions, data cleansing - see rules.go
+	if err := applyRules("input", project, *wv, *tsk, rec); err != nil {
+		return err
 	}
 
-	if project == "lix" {
-		// implicitly all German
-		rec.Language = "de"
-		if rec.Skip != "" {
-			rec.NoMail += " noMail"
-		}
+	if lang, ok := fallbackLanguage(project, rec.Language); ok {
+		rec.langFallback = fmt.Sprintf("%q => %q", rec.Language, lang)
+		rec.Language = lang
 	}
 
-	if rec.SourceTable == "" {
-
-		if rec.Language == "de" {
-			if rec.Sex == 1 {
-				rec.Anrede = "Sehr geehrter Herr "
-			}
-			if rec.Sex == 2 {
-				rec.Anrede = "Sehr geehrte Frau "
-			}
-			if rec.Title != "" {
-				rec.Anrede += rec.Title + " "
-			}
-			rec.Anrede += rec.Lastname
-		}
-		if rec.Language == "en" {
-			if rec.Sex == 1 {
-				rec.Anrede = "Dear Mr. "
-			}
-			if rec.Sex == 2 {
-				rec.Anrede = "Dear Ms. "
-			}
-			if rec.Title != "" {
-				rec.Anrede = "Dear " + rec.Title + " "
-			}
-			rec.Anrede += rec.Lastname
-		}
-
-	} else if rec.SourceTable == "mailadresse" {
-
-		// this database table has no language column;
-		// default language is 'de'.
-		// we derive the language from the 'anrede'
-		if strings.Contains(rec.Anrede, "Dear") {
-			rec.Language = "en"
-		}
-
-	} else if rec.SourceTable == "pds" {
-
-		rec.Anrede = strings.TrimSpace(rec.Anrede)
-		rec.Firstname = strings.TrimSpace(rec.Firstname)
-		rec.Lastname = strings.TrimSpace(rec.Lastname)
-
-		if rec.Anrede != "Mr." && rec.Anrede != "Mrs." {
-			if rec.Firstname != "" && rec.Lastname != "" {
-				rec.Anrede = "Dear " + rec.Firstname + " " + rec.Lastname
-			} else {
-				rec.Anrede = "Dear Sir or Madam"
-			}
-		} else {
-			rec.Anrede = "Dear " + rec.Anrede + " " + rec.Lastname
-		}
-		rec.Language = "en"
-
-	} else if rec.SourceTable == "pds-old" {
-		rec.NoMail += " noMail"
-		rec.Language = "en"
+	// i.e. table mailadresse contains complete salutations
+	if rec.keepAnrede && strings.TrimSpace(rec.Anrede) != "" {
+		rec.salutationKind = salutCSV
+	} else {
+		rec.Anrede, rec.salutationKind, _ = salutation(project, *rec)
 	}
 
 	if rec.ID != "" {
@@ -478,13 +393,15 @@ func (rec *Recipient) SetDerived(project string, wv *WaveT, tsk *TaskT) {
 		}
 	}
 
+	rec.Variant = assignVariant(*rec, *tsk)
+
 	rec.LinkUnsubscribe = rec.LinkUnsub(project, tsk)
 	rec.LinkHelp = rec.LinkHlp(project, tsk)
 
 	// survey identifier
 	y := wv.Year
 	m := wv.Month
-	rec.MonthYear = fmt.Sprintf("%v %v", MonthByInt(int(m), rec.Language), y)
+	rec.MonthYear = formatMonthYear(y, m, rec.Language)
 
 	quarter := int(m-1)/3 + 1
 	rec.QuarterYear = fmt.Sprintf("Q%v %v", quarter, y)
@@ -500,134 +417,76 @@ func (rec *Recipient) SetDerived(project string, wv *WaveT, tsk *TaskT) {
 	rec.ClosingDatePreliminary = formatDate(prelimi, rec.Language)
 	rec.ClosingDateLastDue = formatDate(lastDue, rec.Language)
 
-	tenDaysPast := time.Now().Add(-15 * 24 * 3600 * time.Second)
-	if project == "pds" {
-		tenDaysPast = time.Now().Add(-40 * 24 * 3600 * time.Second)
+	maxAge := cfg.Projects[project].ClosingDateMaxAge
+	if maxAge == 0 {
+		maxAge = 15
 	}
+	tenDaysPast := time.Now().Add(-time.Duration(maxAge) * 24 * time.Hour)
+	var errStale error
 	for _, t := range []time.Time{prelimi, lastDue} {
 		if !t.IsZero() && tenDaysPast.After(t) {
-			log.Fatalf("%v: ClosingDate* %v is older than %v", tsk.Name, formatDate(t, rec.Language), formatDate(tenDaysPast, rec.Language))
+			errStale = fmt.Errorf("%v: ClosingDate* %v is older than %v - %w", tsk.Name, formatDate(t, rec.Language), formatDate(tenDaysPast, rec.Language), errStaleClosingDate)
 		}
 	}
 
-	publication := lastDue.AddDate(0, 0, 1)
-
-	//
-	//
-	// fmt
-	rec.LinkExcel = fmt.Sprintf(
-		`https://fmtdownload.zew.de/fdl/download/public/%v-%02d-%02d_1100/tab.xlsx`,
-		publication.Year(),
-		int(publication.Month()),
-		int(publication.Day()),
-	)
-
-	rec.PressReleaseDe = fmt.Sprintf(
-		`https://fmtdownload.zew.de/fdl/download/public/%v-%02d-%02d_1100/Pressemitteilung_dt.pdf`,
-		publication.Year(),
-		int(publication.Month()),
-		int(publication.Day()),
-	)
-
-	rec.PressReleaseEn = fmt.Sprintf(
-		`https://fmtdownload.zew.de/fdl/download/public/%v-%02d-%02d_1100/Pressemitteilung_en.pdf`,
-		publication.Year(),
-		int(publication.Month()),
-		int(publication.Day()),
-	)
-
-	if project == "fmt" && rec.Language == "en" {
-		if rec.Link != "" && !strings.Contains(string(rec.Link), "&lang_code=en") {
-			// log.Printf("langcode added 3")
-			rec.Link += "&lang_code=en"
-		}
+	// computed URLs - see rules.go
+	if err := applyRules("derived", project, *wv, *tsk, rec); err != nil {
+		return err
 	}
 
+	return errStale
 }
 
-// getText reads template files and fuses them with recipient data;
-// supports partial templates such as footer
-func getText(rec Recipient, project string, tsk TaskT, language string) (subject, body string) {
-
-	templateFile := tsk.Name
-	// check for explicitly different email template
-	if tsk.TemplateName != "" {
-		templateFile = tsk.TemplateName
-	}
+// renderText reads template files and fuses them with recipient data;
+// supports partial templates such as footer and wave specific variants
+func renderText(rec Recipient, project string, wv WaveT, tsk TaskT, language string) (subject, body string, err error) {
 
-	ext := "md"
-	if tsk.HTML {
-		ext = "html"
-	}
-
-	fn := fmt.Sprintf("%v-%v.%v", templateFile, language, ext)
-	pth := filepath.Join(".", "tpl", project, fn)
-	t, err := template.ParseFiles(pth)
+	tsk = variantTask(tsk, rec.Variant)
+	fn, err := resolveTemplate(project, wv, tsk, language)
 	if err != nil {
-		log.Fatalf("could not parse main template %v\n\t%v", fn, err)
+		return "", "", err
 	}
 
-	// adding partials to template tree
-	fnPt := fmt.Sprintf("partial-*-%v.%v", rec.Language, ext)
-	pthPt := filepath.Join(".", "tpl", project, fnPt)
-
-	partials, err := filepath.Glob(pthPt)
+	cids := inlineImages(project, tsk, rec, wv, language)
+	funcs := localeFuncs(language)
+	funcs["cid"] = cidFunc(cids)
+	t, err := parseTemplate(project, fn, funcs)
 	if err != nil {
-		if strings.Contains(err.Error(), "html/template: pattern matches no files") {
-			// no partials
-		} else {
-			log.Fatalf("could not glob  %v\n\t%v", pthPt, err)
-		}
-	} else if len(partials) > 0 {
-		// log.Printf("\tpartials:  %v", strings.Join(partials, ", "))
-		t, err = t.ParseFiles(partials...)
-		if err != nil {
-			log.Fatalf("could not parse partial template %v\n\t%v\n\t%v", fnPt, partials, err)
-		}
-	} else {
-		// log.Printf("\tno partials in:  %v", pthPt)
-	}
-
-	if false {
-		// log.Printf("template parse success %v", t.Name())
-		// log.Print(util.IndentedDump(t.Tree))
-		// t.Tree.Root.Copy()
-		tNames := make([]string, 0, len(t.Templates()))
-		for _, tx := range t.Templates() {
-			tNames = append(tNames, tx.Name())
-		}
-		log.Printf("\ttpls are:  %v", strings.Join(tNames, ", "))
+		return "", "", err
 	}
 
 	sb := &strings.Builder{}
 	err = t.ExecuteTemplate(sb, fn, rec)
 	if err != nil {
-		log.Fatalf("could not execute template %v\n\t%v", fn, err)
+		return "", "", fmt.Errorf("could not execute template %v\n\t%w", fn, err)
 	}
 
 	if strings.Contains(sb.String(), "\r\n") {
-		log.Fatalf("template %v contains \"r\"n - should be only \"n", t.Name())
+		return "", "", fmt.Errorf("template %v contains \"r\"n - should be only \"n", t.Name())
 	}
 
 	lines := strings.Split(sb.String(), "\n")
+	subject, body = lines[0], strings.Join(lines[1:], "\n")
 
-	return lines[0], strings.Join(lines[1:], "\n")
-}
-
-// singleEmail puts together email headers and email body and sends SMTP
-// project - fmt, pds, difi
-// task - invitation, reminder
-func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error {
-
-	if mode != "prod" && mode != "test" {
-		return fmt.Errorf("singleEmail mode must be 'prod' or 'test'; is %v", mode)
+	if tsk.HTML {
+		if err := checkCIDRefs(body, cids); err != nil {
+			return "", "", fmt.Errorf("template %v: %w", fn, err)
+		}
 	}
 
-	if strings.Contains(rec.NoMail, "noMail") {
-		log.Printf("    skipping 'noMail' for %s", rec)
-		return nil
+	if tsk.HTML && tsk.InlineCSS {
+		var warnings []string
+		body, warnings = inlineCSS(body)
+		logCSSWarnings(fn, warnings)
 	}
 
+	return subject, body, nil
+}
+
+// relayHost returns the SMTP relay host for a recipient;
+// default host, task specific host or domain specific host
+func relayHost(rec Recipient, tsk TaskT) (string, RelayHorst, error) {
+
 	relayHostKey := cfg.DefaultHorst
 	if tsk.RelayHost != "" {
 		relayHostKey = tsk.RelayHost
@@ -646,20 +505,23 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 				relayHostKey = key
 			} else {
 				err := fmt.Errorf("email domain %v points to SMTP host %v, which does not exist", domain, key)
-				return err
+				return "", rh, err
 			}
 		} else {
 			log.Printf("\trecipient domain %v - we are not internal", domain)
 		}
 	}
 
-	m := mailyak.New(
-		rh.HostNamePort, // "email.zew.de:587",
-		rh.getAuth(),
-	)
+	return relayHostKey, rh, nil
+}
+
+// composeEmail puts together email headers, email body and attachments;
+// used for sending and for previews.
+// Returns the number of attachments.
+func composeEmail(m *mailyak.MailYak, mode, project string, rec Recipient, wv WaveT, tsk TaskT) (int, error) {
 
 	if cfg.Projects[project].From.Address == "" {
-		return fmt.Errorf("Task.From or Config.DefaultFrom email must be set")
+		return 0, fmt.Errorf("Task.From or Config.DefaultFrom email must be set")
 	}
 	m.From(cfg.Projects[project].From.Address)
 	m.FromName(cfg.Projects[project].From.Name)
@@ -703,31 +565,17 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 		if filepath.Ext(att.Filename) != filepath.Ext(att.Label) {
 			err := fmt.Errorf("file %v must have a label with matching extension", att.Filename)
 			log.Print(err)
-			return err
+			return 0, err
 		}
 
-		lbl := att.Label
-		// xx = tpl.ParseFiles("")
-		// if attachment label contains placeholders, replace with wave data
-		if strings.Contains(lbl, "{{Quarter}}") {
-			lbl = strings.ReplaceAll(lbl, "{{Quarter}}", rec.Quarter)
-		}
-		if strings.Contains(lbl, "{{QuarterYear}}") {
-			lbl = strings.ReplaceAll(lbl, "{{QuarterYear}}", rec.QuarterYear)
-		}
-		if strings.Contains(lbl, "%v") {
-			lbl = fmt.Sprintf(lbl, wv.Year, int(wv.Month))
-		}
+		lbl := attachmentLabel(att, rec, wv)
 
-		pth := filepath.Join(".", "attachments", project, att.Filename)
-		if cfg.AttachmentRoot != "" {
-			pth = filepath.Join(cfg.AttachmentRoot, project, att.Filename)
-		}
+		pth := attachmentPath(project, att)
 
 		fi, err := os.Stat(pth)
 		if err != nil {
 			log.Printf("error getting file info for %v\n\t%v", pth, err)
-			return err
+			return 0, err
 		}
 
 		maxDays := time.Duration(20)
@@ -735,7 +583,7 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 		if time.Now().After(modTimePlus) {
 			err := fmt.Errorf("file over %v days old: %v ", maxDays, filepath.Base(pth))
 			log.Print(err)
-			return err
+			return 0, err
 		}
 
 		// during testing, we send an old file with the suffix "_dummy".exe
@@ -749,18 +597,20 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 		f, err := os.OpenFile(pth, os.O_RDONLY, 0x777)
 		if err != nil {
 			log.Printf("error doing attachment %+v\n\t%v", att, err)
-			return err
+			return 0, err
 		}
+		// inline attachments are moved into multipart/related by mimeMessage()
 		m.Attach(lbl, f)
 		attCtr++
 	}
 
 	m.AddHeader("X-Mailer", "go-massmail")
 
-	rec.SMTP = rh.HostNamePort
-
-	subj, bod := getText(rec, project, tsk, rec.Language)
-	log.Printf("  subject:   %v", subj)
+	subj, bod, err := renderText(rec, project, wv, tsk, rec.Language)
+	if err != nil {
+		return 0, err
+	}
+	logVerbose("  subject:   %v", subj)
 	m.Subject(subj)
 	if tsk.HTML {
 		// m.Plain().Set("Get a real email client")
@@ -770,7 +620,45 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 		m.Plain().Set(bod)
 	}
 
-	log.Printf("  sending %q via %s... to %v with %v attach(s)",
+	return attCtr, nil
+}
+
+// singleEmail puts together email headers and email body and sends SMTP
+// project - fmt, pds, difi
+// task - invitation, reminder
+func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error {
+
+	if mode != "prod" && mode != "test" {
+		return fmt.Errorf("singleEmail mode must be 'prod' or 'test'; is %v", mode)
+	}
+
+	if strings.Contains(rec.NoMail, "noMail") {
+		log.Printf("    skipping 'noMail' for %s", rec)
+		return nil
+	}
+
+	relayHostKey, rh, err := relayHost(rec, tsk)
+	if err != nil {
+		return err
+	}
+
+	auth, err := rh.getAuth()
+	if err != nil {
+		return err
+	}
+	m := mailyak.New(
+		rh.HostNamePort, // "email.zew.de:587",
+		auth,
+	)
+
+	rec.SMTP = rh.HostNamePort
+
+	attCtr, err := composeEmail(m, mode, project, rec, wv, tsk)
+	if err != nil {
+		return err
+	}
+
+	logVerbose("  sending %q via %s... to %v with %v attach(s)",
 		mode, rh.HostNamePort, rec.Lastname, attCtr,
 	)
 
@@ -789,7 +677,22 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 	// 	rh.getAuth(),
 	// 	m,
 	// )
-	err := m.Send()
+	// mailyak knows no multipart/related; see inline-images.go
+	msg, related, err := mimeMessage(m, inlineImages(project, tsk, rec, wv, rec.Language))
+	if err != nil {
+		return err
+	}
+	if related {
+		err = smtp.SendMail(
+			rh.HostNamePort,
+			auth,
+			senderAddress(project, tsk).Address,
+			[]string{rec.Email},
+			msg,
+		)
+	} else {
+		err = m.Send()
+	}
 	if err != nil {
 		return fmt.Errorf(" error sending lib-email  %v:\n\t%w", relayHostKey, err)
 
@@ -798,6 +701,12 @@ func singleEmail(mode, project string, rec Recipient, wv WaveT, tsk TaskT) error
 		// return nil
 	} else {
 		// log.Printf("  lib-email sent")
+		// test runs go to test addresses - they must not enter the variant join
+		if operationMode == "prod" && !tsk.testmode {
+			if err := appendSendLog(project, wv, tsk, rec); err != nil {
+				log.Print(err)
+			}
+		}
 		return nil
 	}
 
@@ -854,7 +763,8 @@ func inBetween(desc string, start, nw, stop time.Time) bool {
 }
 
 // dueTasks searches the config and returns due tasks.
-// test runs are executed 24 hours before in advance
+// test runs are executed 24 hours before in advance.
+// The wave is chosen by flag -wave, task wave or execution time; see waves.go
 func dueTasks() (surveys []string, waves []WaveT, tasks []TaskT) {
 
 	msg := &strings.Builder{}
@@ -862,31 +772,99 @@ func dueTasks() (surveys []string, waves []WaveT, tasks []TaskT) {
 	// nw := time.Now()
 	nw := startTime
 
-	for survey, wvs := range cfg.Waves {
-		last := len(wvs) - 1
-		wv := wvs[last]
+	add := func(survey string, tsk TaskT, note string) {
+		wv, err := taskWave(survey, flagWave, tsk)
+		if err != nil {
+			log.Printf("\t%v-%-22v - skipping: %v", survey, tsk.Name, err)
+			return
+		}
+		surveys = append(surveys, survey)
+		waves = append(waves, wv)
+		tasks = append(tasks, tsk)
+		fmt.Fprintf(msg, "\t%v-%-22v %v   %v", survey, tsk.Name, waveKey(wv), tsk.Description)
+		if note != "" {
+			fmt.Fprintf(msg, "   (%v)", note)
+		}
+		fmt.Fprint(msg, "\n")
+	}
+
+	// runs blocked by the blackout calendar; see blackout.go
+	blocked := &strings.Builder{}
+	block := func(survey string, tsk TaskT, t time.Time, note string) {
+		fmt.Fprintf(blocked, "\t%v-%-22v %v   %v\n", survey, tsk.Name, t.Format("Mon 2006-01-02 15:04"), note)
+	}
+
+	hist, err := readRunHistory()
+	if err != nil {
+		log.Print(err)
+	}
+	// regular window, but delivered already - i.e. by a second cron job or the daemon
+	deliveredAt := func(survey string, tsk TaskT, due time.Time) bool {
+		if operationMode == "prod" && delivered(hist, survey, tsk.Name, due) {
+			log.Printf("\t%v-%-22v - delivered already for %v; skipping", survey, tsk.Name, due.Format("2006-01-02 15:04"))
+			return true
+		}
+		return false
+	}
+
+	for survey := range cfg.Waves {
 		for _, tsk := range cfg.Tasks[survey] {
 
-			if tsk.ExecutionTime.IsZero() && tsk.ExecutionInterval == "" {
+			if tsk.ExecutionTime.IsZero() && intervalDisabled(tsk.ExecutionInterval) {
 				log.Printf("\t%v-%-22v - neither exec time nor invertval; skipping", survey, tsk.Name)
 				// log.Print(util.IndentedDump(tsk))
 				continue
-			} else if tsk.ExecutionTime.IsZero() && tsk.ExecutionInterval != "" {
-				if tsk.ExecutionInterval == "daily" {
-					surveys = append(surveys, survey)
-					waves = append(waves, wv)
-					tasks = append(tasks, tsk)
-					fmt.Fprintf(msg, "\t%v-%-22v   %v\n", survey, tsk.Name, tsk.Description)
+			}
+
+			// interval supersedes execution time; see schedule.go
+			if !intervalDisabled(tsk.ExecutionInterval) {
+				sc, err := taskInterval(tsk)
+				if err != nil {
+					log.Printf("\t%v-%-22v - %v; skipping", survey, tsk.Name, err)
+					continue
+				}
+				if sc.always {
+					add(survey, tsk, "")
 					continue
 				}
+				if fire := sc.Prev(nw); !fire.IsZero() && nw.Before(fire.AddDate(0, 0, 1)) {
+					if eff, note := blackoutTime(survey, tsk, fire); eff.IsZero() {
+						block(survey, tsk, fire, note)
+					}
+				}
+				// fire time  <  now <  fire time + 24hours - fire time shifted by blackout days
+				if prev, _, note := blackoutPrev(survey, tsk, sc, nw); !prev.IsZero() && inBetween("prod", prev, nw, prev.AddDate(0, 0, 1)) {
+					if !deliveredAt(survey, tsk, prev) {
+						tsk.ExecutionTime = prev
+						add(survey, tsk, note)
+					}
+					continue
+				}
+				if operationMode == "test" {
+					if next, note := blackoutNext(survey, tsk, sc, nw); !next.IsZero() && inBetween("advance", next.AddDate(0, 0, -1), nw, next) {
+						tsk.ExecutionTime = next
+						tsk.testmode = true
+						add(survey, tsk, note)
+					}
+				}
+				continue
 			}
 
+			// blackout days shift or block the execution time
+			eff, note := blackoutTime(survey, tsk, tsk.ExecutionTime)
+			if eff.IsZero() {
+				if orig := tsk.ExecutionTime; !nw.Before(orig.AddDate(0, 0, -1)) && nw.Before(orig.AddDate(0, 0, 1)) {
+					block(survey, tsk, orig, note)
+				}
+				continue
+			}
+			tsk.ExecutionTime = eff
+
 			// executionTime  <  now <  executionTime + 24hours
 			if inBetween("prod", tsk.ExecutionTime, nw, tsk.ExecutionTime.AddDate(0, 0, 1)) {
-				surveys = append(surveys, survey)
-				waves = append(waves, wv)
-				tasks = append(tasks, tsk)
-				fmt.Fprintf(msg, "\t%v-%-22v   %v\n", survey, tsk.Name, tsk.Description)
+				if !deliveredAt(survey, tsk, tsk.ExecutionTime) {
+					add(survey, tsk, note)
+				}
 			}
 
 			//
@@ -894,11 +872,8 @@ func dueTasks() (surveys []string, waves []WaveT, tasks []TaskT) {
 			if operationMode == "test" {
 				dayBefore := tsk.ExecutionTime.AddDate(0, 0, -1)
 				if inBetween("advance", dayBefore, nw, dayBefore.AddDate(0, 0, 1)) {
-					surveys = append(surveys, survey)
-					waves = append(waves, wv)
 					tsk.testmode = true
-					tasks = append(tasks, tsk)
-					fmt.Fprintf(msg, "\t%-24v   %v\n", survey+"-"+tsk.Name, tsk.Description)
+					add(survey, tsk, note)
 				}
 			}
 
@@ -906,6 +881,23 @@ func dueTasks() (surveys []string, waves []WaveT, tasks []TaskT) {
 
 	}
 
+	// missed runs; see history.go
+	ods := overdueTasks(nw, hist)
+	reportOverdue(ods)
+	if operationMode == "prod" && !dueListing {
+		for _, od := range ods {
+			if catchUp(od, nw, !unattended) {
+				tsk := od.Task
+				tsk.ExecutionTime = od.Due
+				add(od.Project, tsk, "catch up")
+			}
+		}
+	}
+
+	if blocked.Len() > 0 {
+		log.Printf("blocked by blackout calendar:\n%v", blocked)
+	}
+
 	if len(surveys) > 0 {
 		log.Printf("%02v due tasks found:\n%v\n", len(surveys), msg)
 	} else {
@@ -1006,6 +998,14 @@ func getCSV(project string, wv WaveT, tsk TaskT, doBackup bool) ([]*Recipient, e
 		return nil, fmt.Errorf("getCSV(): seek back to start error %w", err)
 	}
 
+	return parseCSV(inFile, project, wv, tsk)
+
+}
+
+// parseCSV unmarshals recipients and sets their derived fields;
+// separated from getCSV() for reading local files without download or backup
+func parseCSV(inFile io.ReadSeeker, project string, wv WaveT, tsk TaskT) ([]*Recipient, error) {
+
 	recs := []*Recipient{} // recipients
 
 	// set option for gocsv lib
@@ -1018,16 +1018,45 @@ func getCSV(project string, wv WaveT, tsk TaskT, doBackup bool) ([]*Recipient, e
 		return r
 	})
 
-	if err := gocsv.UnmarshalFile(inFile, &recs); err != nil {
+	if err := gocsv.Unmarshal(inFile, &recs); err != nil {
 		return nil, fmt.Errorf("getCSV(): unmarshal CSV error %w", err)
 	}
 
+	// second pass - preserving columns unknown to Recipient
+	_, err := inFile.Seek(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("getCSV(): seek back to start error %w", err)
+	}
+	rows, err := readFields(inFile)
+	if err != nil {
+		return nil, fmt.Errorf("getCSV(): %w", err)
+	}
+	if len(rows) != len(recs) {
+		return nil, fmt.Errorf("getCSV(): %v rows for all columns, but %v recipients", len(rows), len(recs))
+	}
+	for idx, rec := range recs {
+		rec.Fields = rows[idx]
+	}
+
 	checkMonotonIncrease(recs)
 
 	// always set derived fields after loading or re-loading
+	// stale closing dates are reported after all recipients are derived -
+	// callers such as lint and preview continue with the records
 	log.Printf("SetDerived() for %v - %v", project, tsk.Name)
+	var errStale error
 	for _, rec := range recs {
-		rec.SetDerived(project, &wv, &tsk)
+		err := rec.SetDerived(project, &wv, &tsk)
+		if errors.Is(err, errStaleClosingDate) {
+			errStale = err
+			continue
+		}
+		if err != nil {
+			return recs, fmt.Errorf("getCSV(): %w", err)
+		}
+	}
+	if errStale != nil {
+		return recs, fmt.Errorf("getCSV(): %w", errStale)
 	}
 
 	//
@@ -1102,28 +1131,41 @@ func testRecipients(project string, wv WaveT, tsk TaskT, recs []*Recipient) ([]*
 // and emails each recipients using singleEmail().
 // There is a dry run (preflight) to catch missing elements and
 // then the "prod" run.
-func processTask(project string, wv WaveT, tsk TaskT) {
+func processTask(project string, wv WaveT, tsk TaskT) error {
 
 	log.Printf("\n\n\t%v-%-22v   %v - %v att(s)\n\t==================", project, tsk.Name, tsk.Description, len(tsk.Attachments))
 
+	// see approval.go
+	if unattended && operationMode == "prod" && !tsk.testmode {
+		if err := checkApproval(project, wv, tsk, flagApproval); err != nil {
+			return err
+		}
+	}
+
 	recs, err := getCSV(project, wv, tsk, false)
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 
 	for idx, rec := range recs {
 		err := rec.CheckEmail()
 		if err != nil {
-			log.Printf(" checking email for row  %d:   %v", idx, err)
-			return
+			return fmt.Errorf(" checking email for row  %d:   %w", idx, err)
 		}
 	}
 
+	if err := checkRequiredFields(tsk, recs); err != nil {
+		return err
+	}
+
+	logVariants(tsk, recs)
+	logLanguageFallbacks(recs)
+	logSalutationFallbacks(recs)
+	logLocalBatches(tsk, recs)
+
 	recs, err = testRecipients(project, wv, tsk, recs)
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 
 	log.Print("\n\t preflight")
@@ -1138,112 +1180,113 @@ func processTask(project string, wv WaveT, tsk TaskT) {
 		)
 		err := singleEmail("test", project, *rec, wv, tsk)
 		if err != nil {
-			log.Printf("error in preflight run:\n\t%v\n\t%s", err, rec)
-			return
+			return fmt.Errorf("error in preflight run:\n\t%w\n\t%s", err, rec)
 		}
 	}
 
 	//
 	//
-	// menu skip - continue...
+	// menu skip - continue...; keys until the task is done
+	stopKeys := startKeys(fmt.Sprintf("%v-%v", project, tsk.Name))
+	defer stopKeys()
 	const waitSeconds = 8
-	bt1 := loopAsync(waitSeconds)
-	if bt1 == 97 {
-		log.Print("aborting...")
-		os.Exit(0)
-	} else if bt1 == 99 {
-		log.Print("continue")
-	} else if bt1 == 115 {
-		log.Print("next task")
-		return
-	} else {
-		log.Print("loopAsync returned invalid byte code; aborting...")
-		os.Exit(0)
+	if err := menuWait(waitSeconds); err != nil {
+		log.Print(err)
+		return err
 	}
 
 	//
 	//
 	// waiting for startTime
-	const interval = 5
-	dist := time.Until(startTime)
-	if dist > time.Second {
-		ticker := time.NewTicker(interval * time.Second)
-		defer ticker.Stop()
-		strStartTime := startTime.Format(stfmt)
-		// log.Printf("%5d secs until %s", dist.Round(time.Second)/time.Second, strStartTime)
-		log.Printf("%5s  until %s", formatDuration(dist), strStartTime)
-	labelFor:
-		for {
-			select {
-			case <-ticker.C:
-				dist := time.Until(startTime)
-				// log.Printf("%5d secs until %s", dist.Round(time.Second)/time.Second, strStartTime)
-				log.Printf("%5s  until %s", formatDuration(dist), strStartTime)
-				if dist > interval*time.Second {
-					// wait for next tick
-				} else {
-					log.Printf("   %5.2f secs until precise start time", float64(dist.Round(time.Second))/float64(time.Second))
-					time.Sleep(dist)
-					break labelFor
-				}
-			}
+	if time.Until(startTime) > time.Second {
+		if err := waitUntil(startTime); err != nil {
+			return err
 		}
 
 		// refresh recipients
 		recs, err = getCSV(project, wv, tsk, true)
 		if err != nil {
-			log.Print(err)
-			return
+			return err
 		}
 
 		recs, err = testRecipients(project, wv, tsk, recs)
 		if err != nil {
-			log.Print(err)
-			return
+			return err
 		}
 
 	}
 
 	log.Print("\n\t prod")
-	for idx1, rec := range recs {
-		log.Printf(
-			"#%03v %-28v %v  %v%v %v",
+	// batches by recipient local time; test recipients are not kept waiting
+	batches, _ := localBatches(tsk, recs, startTime)
+	sendingStart(len(recs))
+	idx1 := 0
+	for _, batch := range batches {
+		if operationMode == "prod" && !tsk.testmode && time.Until(batch.At) > time.Second {
+			if err := waitUntil(batch.At); err != nil {
+				log.Printf("%v after %v of %v messages", err, idx1, len(recs))
+				return err
+			}
+		}
+		for _, rec := range batch.Recs {
+			if err := checkpoint(); err != nil {
+				log.Printf("%v after %v of %v messages", err, idx1, len(recs))
+				return err
+			}
+			idx1++
+			logVerbose(
+				"#%03v %-28v %v  %v%v %v",
 
-			idx1+1,
+				idx1,
 
-			rec.Email,
-			rec.Anrede,
+				rec.Email,
+				rec.Anrede,
 
-			// rec.ClosingDatePreliminary,
-			rec.Language, rec.Sex,
-			rec.MonthYear,
-		)
-		err := singleEmail("prod", project, *rec, wv, tsk)
-		if err != nil {
-			log.Printf("error in prod run:\n\t%v\n\t%s", err, rec)
-			// log.Printf("\t%v", project)
-			// log.Printf("\t%v", wv)
-			// log.Printf("\t%v", tsk)
-			// log.Printf("\t%v", *rec)
-			return
+				// rec.ClosingDatePreliminary,
+				rec.Language, rec.Sex,
+				rec.MonthYear,
+			)
+			t0 := time.Now()
+			err := singleEmail("prod", project, *rec, wv, tsk)
+			sentOne(time.Since(t0))
+			if err != nil {
+				// log.Printf("\t%v", project)
+				// log.Printf("\t%v", wv)
+				// log.Printf("\t%v", tsk)
+				// log.Printf("\t%v", *rec)
+				if idx1 > 1 {
+					return fmt.Errorf("%w after %v of %v messages:\n\t%w\n\t%s", errPartialRun, idx1-1, len(recs), err, rec)
+				}
+				return fmt.Errorf("error in prod run:\n\t%w\n\t%s", err, rec)
+			}
 		}
-
 	}
 
+	return nil
 }
 
-// iterTasks reads dueTasks() and executes them using processTask
-func iterTasks() {
+// iterTasks reads dueTasks() and executes them using runTask;
+// returns the number of failed tasks; after an abort, the remaining tasks count as failed
+func iterTasks() (failed int) {
 
 	surveys, waves, tasks := dueTasks()
 	for idx, survey := range surveys {
-		processTask(survey, waves[idx], tasks[idx])
+		err := runTask(survey, waves[idx], tasks[idx])
+		if errors.Is(err, errRunAborted) {
+			return failed + len(surveys) - idx
+		}
+		if err != nil && !errors.Is(err, errTaskSkipped) {
+			failed++
+		}
 	}
-
+	return failed
 }
 
 func userStdin(prompt string) (string, error) {
 	fmt.Fprint(os.Stderr, prompt)
+	if stdinShared() {
+		return readLine()
+	}
 	reader := bufio.NewReader(os.Stdin)
 	username, err := reader.ReadString('\n')
 	if err != nil {
@@ -1255,6 +1298,16 @@ func userStdin(prompt string) (string, error) {
 
 func passStdin(prompt string) (string, error) {
 	fmt.Fprint(os.Stderr, prompt)
+	if stdinShared() && term.IsTerminal(int(os.Stdin.Fd())) {
+		restore, err := makeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return "", err
+		}
+		pw, err := readLine()
+		restore()
+		fmt.Fprintln(os.Stderr)
+		return pw, err
+	}
 	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
 	fmt.Fprintln(os.Stderr) // add newline after password input
 	if err != nil {
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVStaleClosingDate(t *testing.T) {
+
+	prevCfg, prevLoc := cfg, loc
+	defer func() { cfg, loc = prevCfg, prevLoc }()
+	loc = time.UTC
+	cfg = configT{Projects: map[string]ProjectT{"p": {}}}
+
+	csv := "id;email;sex;lastname;lang\n" +
+		"1;a@example.com;1;Müller;de\n" +
+		"2;b@example.com;2;Smith;en\n" +
+		"3;c@example.com;1;Meier;de\n"
+
+	last := time.Now().AddDate(0, -3, 0)
+	wv := WaveT{Year: last.Year(), Month: last.Month(), ClosingDateLastDue: last}
+
+	recs, err := parseCSV(strings.NewReader(csv), "p", wv, TaskT{Name: "reminder"})
+	if !errors.Is(err, errStaleClosingDate) {
+		t.Fatalf("want errStaleClosingDate; got %v", err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("want 3 recipients; got %v", len(recs))
+	}
+	for _, rec := range recs {
+		if rec.Anrede == "" || rec.MonthYear == "" || rec.ClosingDateLastDue == "" {
+			t.Errorf("recipient %v: derived fields missing - %q %q %q", rec.ID, rec.Anrede, rec.MonthYear, rec.ClosingDateLastDue)
+		}
+	}
+}
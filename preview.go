@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/domodwyer/mailyak/v3"
+)
+
+// previewT is a rendered message, parsed back from its MIME representation
+type previewT struct {
+	File        string // .eml file name
+	Recipient   string
+	Headers     [][2]string
+	Subject     string
+	Plain       string
+	HTML        string
+	Attachments []string
+	Err         string
+}
+
+// taskByName returns the task config
+func taskByName(project, name string) (TaskT, error) {
+	for _, tsk := range cfg.Tasks[project] {
+		if tsk.Name == name {
+			return tsk, nil
+		}
+	}
+	return TaskT{}, fmt.Errorf("project %v has no task %v", project, name)
+}
+
+// previewSample selects one recipient per language and variant
+// plus the recipients with the given IDs
+func previewSample(recs []*Recipient, ids []string) []*Recipient {
+
+	sample := []*Recipient{}
+	seen := map[*Recipient]bool{}
+
+	langs := map[string]*Recipient{}
+	for _, rec := range recs {
+		if strings.Contains(rec.NoMail, "noMail") {
+			continue
+		}
+		key := rec.Language + " " + rec.Variant
+		if _, ok := langs[key]; !ok {
+			langs[key] = rec
+		}
+	}
+	sortedLangs := make([]string, 0, len(langs))
+	for lang := range langs {
+		sortedLangs = append(sortedLangs, lang)
+	}
+	sort.Strings(sortedLangs)
+	for _, lang := range sortedLangs {
+		sample = append(sample, langs[lang])
+		seen[langs[lang]] = true
+	}
+
+	for _, id := range ids {
+		found := false
+		for _, rec := range recs {
+			if rec.ID == id {
+				found = true
+				if !seen[rec] {
+					sample = append(sample, rec)
+					seen[rec] = true
+				}
+			}
+		}
+		if !found {
+			log.Printf("  preview: no recipient with ID %v", id)
+		}
+	}
+
+	return sample
+}
+
+// previewTask renders the full MIME message for sample recipients of a task,
+// writes them as .eml files and creates an index.html
+// showing headers, HTML body, text body and attachments side by side.
+// Nothing is sent.
+func previewTask(project, taskName, waveKey string, ids []string, outDir string) error {
+
+	if project == "" || taskName == "" {
+		return fmt.Errorf("preview requires -project and -task")
+	}
+	tsk, err := taskByName(project, taskName)
+	if err != nil {
+		return err
+	}
+	wv, _, err := selectWave(project, waveKey, tsk, taskTime(tsk))
+	if err != nil {
+		return err
+	}
+	if err := checkWave(project, wv, taskTime(tsk)); err != nil {
+		log.Printf("  preview: %v", err)
+	}
+
+	recs, err := getCSV(project, wv, tsk, false)
+	if err != nil && !errors.Is(err, errStaleClosingDate) {
+		return err
+	}
+	if err != nil {
+		log.Printf("  preview: %v", err)
+	}
+
+	dir := filepath.Join(outDir, fmt.Sprintf("%v-%v-%d-%02d", project, tsk.Name, wv.Year, wv.Month))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	previews := []previewT{}
+	for idx, rec := range previewSample(recs, ids) {
+
+		pv := previewT{
+			File:      strings.TrimSuffix(fmt.Sprintf("%02d-%v-%v-%v", idx+1, rec.ID, rec.Language, rec.Variant), "-") + ".eml",
+			Recipient: rec.String(),
+		}
+
+		raw, err := renderMime(project, *rec, wv, tsk)
+		if err != nil {
+			pv.Err = err.Error()
+			previews = append(previews, pv)
+			continue
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, pv.File), raw, 0644); err != nil {
+			return err
+		}
+
+		parsed, err := parseMessage(raw)
+		if err != nil {
+			pv.Err = err.Error()
+		} else {
+			parsed.File = pv.File
+			parsed.Recipient = pv.Recipient
+			pv = parsed
+		}
+		previews = append(previews, pv)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = previewIndexTpl.Execute(f, map[string]any{
+		"Title":    fmt.Sprintf("%v - %v - %d-%02d", project, tsk.Name, wv.Year, wv.Month),
+		"Previews": previews,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("  preview: %v message(s) in %v", len(previews), filepath.Join(dir, "index.html"))
+	return nil
+}
+
+// renderMime composes the message exactly as singleEmail() does,
+// but returns its MIME representation instead of sending
+func renderMime(project string, rec Recipient, wv WaveT, tsk TaskT) ([]byte, error) {
+
+	_, rh, err := relayHost(rec, tsk)
+	if err != nil {
+		return nil, err
+	}
+	m := mailyak.New(rh.HostNamePort, nil) // no auth required
+	rec.SMTP = rh.HostNamePort
+
+	if _, err := composeEmail(m, "prod", project, rec, wv, tsk); err != nil {
+		return nil, err
+	}
+
+	msg, _, err := mimeMessage(m, inlineImages(project, tsk, rec, wv, rec.Language))
+	return msg, err
+}
+
+// parseMessage extracts headers, bodies and attachments from a MIME message
+func parseMessage(raw []byte) (previewT, error) {
+
+	pv := previewT{}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return pv, err
+	}
+
+	dec := new(mime.WordDecoder)
+	keys := make([]string, 0, len(msg.Header))
+	for k := range msg.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range msg.Header[k] {
+			decoded, err := dec.DecodeHeader(v)
+			if err != nil {
+				decoded = v
+			}
+			pv.Headers = append(pv.Headers, [2]string{k, decoded})
+			if k == "Subject" {
+				pv.Subject = decoded
+			}
+		}
+	}
+
+	err = walkPart(&pv, textproto.MIMEHeader(msg.Header), msg.Body)
+	return pv, err
+}
+
+// walkPart descends into multipart containers
+func walkPart(pv *previewT, header textproto.MIMEHeader, body io.Reader) error {
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("content type %q: %w", header.Get("Content-Type"), err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkPart(pv, part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	// quoted-printable is decoded by multipart.Reader
+	if strings.EqualFold(header.Get("Content-Transfer-Encoding"), "base64") {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	bts, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	_, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	fn := dispParams["filename"]
+	if fn == "" {
+		fn = params["filename"]
+	}
+
+	switch {
+	case fn == "" && mediaType == "text/plain":
+		pv.Plain = string(bts)
+	case fn == "" && mediaType == "text/html":
+		pv.HTML = string(bts)
+	default:
+		desc := fmt.Sprintf("%v - %v - %v kB", fn, mediaType, (len(bts)+1023)/1024)
+		if cid := header.Get("Content-ID"); cid != "" {
+			desc += " - Content-ID " + cid
+		}
+		pv.Attachments = append(pv.Attachments, desc)
+	}
+
+	return nil
+}
+
+var previewIndexTpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<style>
+* { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; }
+body { margin: 1rem; }
+section { border-top: 2px solid #888; margin-bottom: 2rem; }
+.grid { display: grid; grid-template-columns: 1fr 1fr 1fr 1fr; gap: 1rem; }
+.grid > div { overflow: auto; max-height: 40rem; }
+table { font-size: 80%; border-collapse: collapse; }
+td { vertical-align: top; padding: 0.1rem 0.4rem; border-bottom: 1px solid #ddd; word-break: break-all; }
+iframe { width: 100%; height: 38rem; border: 1px solid #ddd; }
+pre { white-space: pre-wrap; font-family: monospace; font-size: 85%; }
+.err { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Previews}}
+<section>
+	<h2>{{.Subject}}</h2>
+	<p>{{.Recipient}} - <a href="{{.File}}">{{.File}}</a></p>
+	{{if .Err}}<p class="err">{{.Err}}</p>{{end}}
+	<div class="grid">
+		<div>
+			<h3>Headers</h3>
+			<table>
+			{{range .Headers}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+			{{end}}
+			</table>
+		</div>
+		<div>
+			<h3>HTML body</h3>
+			{{if .HTML}}<iframe sandbox srcdoc="{{.HTML}}"></iframe>{{else}}<p>none</p>{{end}}
+		</div>
+		<div>
+			<h3>Text body</h3>
+			<pre>{{.Plain}}</pre>
+		</div>
+		<div>
+			<h3>Attachments</h3>
+			<ul>
+			{{range .Attachments}}<li>{{.}}</li>
+			{{else}}<li>none</li>
+			{{end}}
+			</ul>
+		</div>
+	</div>
+</section>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Field returns the raw value of any CSV column;
+// for usage in templates
+//
+//	{{.Field "company"}}
+//
+// Columns without a counterpart in Recipient,
+// for instance the lix mixin columns, are only reachable this way.
+func (rec Recipient) Field(name string) string {
+	val, ok := rec.Fields[name]
+	if !ok && rec.missingFields != nil {
+		rec.missingFields[name] = true
+	}
+	return val
+}
+
+// readFields reads a recipient CSV a second time - after gocsv is done -
+// and returns every row as a map of column names to values.
+// The rows correspond one-to-one to the records unmarshalled by gocsv.
+func readFields(in io.Reader) ([]map[string]string, error) {
+
+	r := csv.NewReader(in)
+	r.Comma = ';'
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("readFields(): %w", err)
+	}
+	if len(rows) < 1 {
+		return nil, nil
+	}
+
+	header := make([]string, len(rows[0]))
+	for idx, col := range rows[0] {
+		col = strings.TrimPrefix(col, "\uFEFF") // byte order mark, i.e. from MS Excel
+		header[idx] = strings.TrimSpace(col)
+	}
+
+	ret := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		flds := make(map[string]string, len(header))
+		for idx, col := range header {
+			if idx < len(row) {
+				flds[col] = row[idx]
+			}
+		}
+		ret = append(ret, flds)
+	}
+
+	return ret, nil
+}
+
+// checkRequiredFields ensures that every column in tsk.RequiredFields
+// exists in the CSV and is non-empty for every recipient to be emailed.
+func checkRequiredFields(tsk TaskT, recs []*Recipient) error {
+
+	if len(tsk.RequiredFields) == 0 {
+		return nil
+	}
+
+	msg := &strings.Builder{}
+	for _, col := range tsk.RequiredFields {
+		missing := 0
+		empty := []string{}
+		for idx, rec := range recs {
+			if strings.Contains(rec.NoMail, "noMail") {
+				continue
+			}
+			val, ok := rec.Fields[col]
+			if !ok {
+				missing++
+				continue
+			}
+			if strings.TrimSpace(val) == "" {
+				empty = append(empty, fmt.Sprintf("row %d - %s", idx+1, rec))
+			}
+		}
+		if missing > 0 {
+			fmt.Fprintf(msg, "\tcolumn %q is missing in CSV\n", col)
+		}
+		if len(empty) > 0 {
+			fmt.Fprintf(msg, "\tcolumn %q is empty for %v recipient(s)\n", col, len(empty))
+			for _, e := range empty {
+				fmt.Fprintf(msg, "\t\t%v\n", e)
+			}
+		}
+	}
+
+	if msg.Len() > 0 {
+		return fmt.Errorf("%v - required fields:\n%v", tsk.Name, msg)
+	}
+	return nil
+}
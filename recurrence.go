@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurrenceT generates waves and task execution times for recurring surveys.
+//
+//	"recurrence": {
+//	  "start":  "2026-08",
+//	  "anchor": "second monday",
+//	  "closing_date_preliminary": "anchor next friday 17:00",
+//	  "closing_date_last_due":    "preliminary +3d",
+//	  "tasks": {
+//	    "invitation": "anchor 08:00",
+//	    "results-a":  "last_due next tuesday 11:00"
+//	  },
+//	  "holidays": "de-bw"
+//	}
+//
+// Dates on holidays are moved to the next working day.
+type RecurrenceT struct {
+	Start  string `json:"start"`            // first generated wave - 2026-08
+	Months []int  `json:"months,omitempty"` // i.e. [1, 4, 7, 10] for quarterly; empty means every month
+
+	// day within the wave month: "first monday", "last friday", "day 5"
+	Anchor string `json:"anchor"`
+
+	// relative to anchor, preliminary, last_due:
+	// "+3d" "-1w" "next friday" "17:00" - or absolute "2026-07-17 17:00"
+	ClosingDatePreliminary string `json:"closing_date_preliminary,omitempty"`
+	ClosingDateLastDue     string `json:"closing_date_last_due,omitempty"`
+
+	// task name => execution time, relative as above
+	Tasks map[string]string `json:"tasks,omitempty"`
+
+	Holidays      string   `json:"holidays,omitempty"`       // "de" - default, "de-bw" or "none"
+	ExtraHolidays []string `json:"extra_holidays,omitempty"` // 2006-01-02
+
+	// exceptions by wave - 2026-07
+	Overrides map[string]RecurrenceOverrideT `json:"overrides,omitempty"`
+}
+
+// RecurrenceOverrideT replaces the settings of RecurrenceT for a single wave
+type RecurrenceOverrideT struct {
+	Skip bool `json:"skip,omitempty"`
+
+	Anchor                 string            `json:"anchor,omitempty"`
+	ClosingDatePreliminary string            `json:"closing_date_preliminary,omitempty"`
+	ClosingDateLastDue     string            `json:"closing_date_last_due,omitempty"`
+	Tasks                  map[string]string `json:"tasks,omitempty"`
+}
+
+var recurWeekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+var recurOrdinals = map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "last": -1}
+
+var (
+	recurClockRe  = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	recurOffsetRe = regexp.MustCompile(`^([+-]\d+)([dw])$`)
+)
+
+// recurAbsolute parses "2026-07-17", "2026-07-17 17:00" and "2026-07-17T17:00"
+func recurAbsolute(spec string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(spec), loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// recurClock sets hour and minute
+func recurClock(t time.Time, tok string) (time.Time, bool) {
+	m := recurClockRe.FindStringSubmatch(tok)
+	if m == nil {
+		return t, false
+	}
+	h, _ := strconv.Atoi(m[1])
+	mi, _ := strconv.Atoi(m[2])
+	if h > 23 || mi > 59 {
+		return t, false
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), h, mi, 0, 0, loc), true
+}
+
+// anchorDate evaluates "second monday", "last friday", "day 5" - optionally followed by "08:00"
+func anchorDate(spec string, year int, month time.Month) (time.Time, error) {
+
+	if t, ok := recurAbsolute(spec); ok {
+		return t, nil
+	}
+
+	toks := strings.Fields(strings.ToLower(spec))
+	if len(toks) < 2 {
+		return time.Time{}, fmt.Errorf("anchor %q: requires 'day 5' or 'first monday'", spec)
+	}
+
+	var t time.Time
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if toks[0] == "day" {
+		d, err := strconv.Atoi(toks[1])
+		if err != nil || d < 1 || d > 31 {
+			return t, fmt.Errorf("anchor %q: invalid day", spec)
+		}
+		t = first.AddDate(0, 0, d-1)
+		if t.Month() != month {
+			return t, fmt.Errorf("anchor %q: no such day in %v-%02d", spec, year, int(month))
+		}
+	} else {
+		n, ok1 := recurOrdinals[toks[0]]
+		wd, ok2 := recurWeekdays[toks[1]]
+		if !ok1 || !ok2 {
+			return t, fmt.Errorf("anchor %q: requires 'day 5' or 'first monday'", spec)
+		}
+		if n > 0 {
+			t = first.AddDate(0, 0, (int(wd)-int(first.Weekday())+7)%7+7*(n-1))
+		} else {
+			last := first.AddDate(0, 1, -1)
+			t = last.AddDate(0, 0, -((int(last.Weekday()) - int(wd) + 7) % 7))
+		}
+	}
+
+	for _, tok := range toks[2:] {
+		var ok bool
+		if t, ok = recurClock(t, tok); !ok {
+			return t, fmt.Errorf("anchor %q: unknown token %q", spec, tok)
+		}
+	}
+	return t, nil
+}
+
+// relativeDate evaluates "preliminary +3d 17:00", "last_due next tuesday 11:00";
+// the clock time of the reference is kept, unless given
+func relativeDate(spec string, refs map[string]time.Time) (time.Time, error) {
+
+	if t, ok := recurAbsolute(spec); ok {
+		return t, nil
+	}
+
+	toks := strings.Fields(strings.ToLower(spec))
+	if len(toks) < 1 {
+		return time.Time{}, fmt.Errorf("empty date spec")
+	}
+	t, ok := refs[toks[0]]
+	if !ok {
+		return t, fmt.Errorf("%q: must start with anchor, preliminary or last_due", spec)
+	}
+	if t.IsZero() {
+		return t, fmt.Errorf("%q: %v is not set", spec, toks[0])
+	}
+
+	for i := 1; i < len(toks); i++ {
+		tok := toks[i]
+		if m := recurOffsetRe.FindStringSubmatch(tok); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			if m[2] == "w" {
+				n *= 7
+			}
+			t = t.AddDate(0, 0, n)
+			continue
+		}
+		if tok == "next" && i+1 < len(toks) {
+			wd, ok := recurWeekdays[toks[i+1]]
+			if !ok {
+				return t, fmt.Errorf("%q: unknown weekday %q", spec, toks[i+1])
+			}
+			days := (int(wd) - int(t.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			t = t.AddDate(0, 0, days)
+			i++
+			continue
+		}
+		if t2, ok := recurClock(t, tok); ok {
+			t = t2
+			continue
+		}
+		return t, fmt.Errorf("%q: unknown token %q", spec, tok)
+	}
+	return t, nil
+}
+
+// skipHolidays moves t to the next day, which is no holiday;
+// a date moved past a holiday does not land on a weekend
+func (r RecurrenceT) skipHolidays(t time.Time) time.Time {
+	if r.Holidays == "none" || t.IsZero() {
+		return t
+	}
+	region := r.Holidays
+	if region == "" {
+		region = "de"
+	}
+	moved := false
+	for i := 0; i < 14; i++ {
+		wd := t.Weekday()
+		weekend := wd == time.Saturday || wd == time.Sunday
+		if holidayName(t, region, r.ExtraHolidays) == "" && !(moved && weekend) {
+			break
+		}
+		t = t.AddDate(0, 0, 1)
+		moved = true
+	}
+	return t
+}
+
+// occurrenceT is a generated wave and the execution times of its tasks
+type occurrenceT struct {
+	Wave  WaveT
+	Tasks map[string]time.Time
+}
+
+// occurrence generates the wave for a month;
+// manual waves in config.json take precedence over generated closing dates
+func (r RecurrenceT) occurrence(project string, year int, month time.Month) (occurrenceT, bool, error) {
+
+	key := fmt.Sprintf("%d-%02d", year, month)
+	ovr := r.Overrides[key]
+	if ovr.Skip {
+		return occurrenceT{}, false, nil
+	}
+	pick := func(o, dflt string) string {
+		if o != "" {
+			return o
+		}
+		return dflt
+	}
+
+	oc := occurrenceT{
+		Wave:  WaveT{Year: year, Month: month},
+		Tasks: map[string]time.Time{},
+	}
+
+	anchor, err := anchorDate(pick(ovr.Anchor, r.Anchor), year, month)
+	if err != nil {
+		return oc, false, fmt.Errorf("%v: %w", key, err)
+	}
+	anchor = r.skipHolidays(anchor)
+	refs := map[string]time.Time{"anchor": anchor}
+
+	if spec := pick(ovr.ClosingDatePreliminary, r.ClosingDatePreliminary); spec != "" {
+		t, err := relativeDate(spec, refs)
+		if err != nil {
+			return oc, false, fmt.Errorf("%v: closing_date_preliminary %w", key, err)
+		}
+		oc.Wave.ClosingDatePreliminary = r.skipHolidays(t)
+		refs["preliminary"] = oc.Wave.ClosingDatePreliminary
+	}
+	if spec := pick(ovr.ClosingDateLastDue, r.ClosingDateLastDue); spec != "" {
+		t, err := relativeDate(spec, refs)
+		if err != nil {
+			return oc, false, fmt.Errorf("%v: closing_date_last_due %w", key, err)
+		}
+		oc.Wave.ClosingDateLastDue = r.skipHolidays(t)
+	}
+
+	for _, wv := range cfg.Waves[project] {
+		if waveKey(wv) == key {
+			oc.Wave = wv
+		}
+	}
+	refs["preliminary"] = oc.Wave.ClosingDatePreliminary
+	refs["last_due"] = oc.Wave.ClosingDateLastDue
+
+	for name, spec := range r.Tasks {
+		if o, ok := ovr.Tasks[name]; ok {
+			spec = o
+		}
+		t, err := relativeDate(spec, refs)
+		if err != nil {
+			return oc, false, fmt.Errorf("%v: task %v %w", key, name, err)
+		}
+		oc.Tasks[name] = r.skipHolidays(t)
+	}
+
+	return oc, true, nil
+}
+
+// occurrences generates waves from Start until two months after until
+func (r RecurrenceT) occurrences(project string, until time.Time) ([]occurrenceT, error) {
+
+	start, err := time.ParseInLocation("2006-01", r.Start, loc)
+	if err != nil {
+		return nil, fmt.Errorf("recurrence start %q: requires 2006-01", r.Start)
+	}
+	end := time.Date(until.Year(), until.Month()+2, 1, 0, 0, 0, 0, loc)
+
+	ocs := []occurrenceT{}
+	for m := start; !m.After(end); m = m.AddDate(0, 1, 0) {
+		if len(r.Months) > 0 && !slices.Contains(r.Months, int(m.Month())) {
+			continue
+		}
+		oc, ok, err := r.occurrence(project, m.Year(), m.Month())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ocs = append(ocs, oc)
+		}
+	}
+	return ocs, nil
+}
+
+// generateWaves appends generated waves to cfg.Waves
+// and sets execution time and wave of recurring tasks
+// to their next occurrence after startTime
+func generateWaves() error {
+
+	projects := make([]string, 0, len(cfg.Projects))
+	for project := range cfg.Projects {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	for _, project := range projects {
+		r := cfg.Projects[project].Recurrence
+		if r == nil {
+			continue
+		}
+		if r.Holidays != "" && r.Holidays != "none" && !holidayRegions[strings.ToLower(r.Holidays)] {
+			return fmt.Errorf("project %v: unknown holiday region %q", project, r.Holidays)
+		}
+		ocs, err := r.occurrences(project, startTime)
+		if err != nil {
+			return fmt.Errorf("project %v: %w", project, err)
+		}
+
+		if cfg.Waves == nil {
+			cfg.Waves = map[string][]WaveT{}
+		}
+		for _, oc := range ocs {
+			if _, err := waveByKey(project, waveKey(oc.Wave)); err != nil {
+				cfg.Waves[project] = append(cfg.Waves[project], oc.Wave)
+			}
+		}
+		wvs := cfg.Waves[project]
+		sort.SliceStable(wvs, func(i, j int) bool {
+			return waveStart(wvs[i]).Before(waveStart(wvs[j]))
+		})
+
+		for idx, tsk := range cfg.Tasks[project] {
+			name := tsk.Name
+			if _, ok := r.Tasks[name]; !ok {
+				name = tsk.sameAsOf
+			}
+			if _, ok := r.Tasks[name]; !ok {
+				continue
+			}
+			for _, oc := range ocs {
+				t := oc.Tasks[name]
+				if t.AddDate(0, 0, 1).After(startTime) {
+					tsk.ExecutionTime = t
+					tsk.Wave = waveKey(oc.Wave)
+					cfg.Tasks[project][idx] = tsk
+					log.Printf("%v-%-22v recurs %v - wave %v", project, tsk.Name, t.Format("Mon 2006-01-02 15:04"), tsk.Wave)
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkipHolidays(t *testing.T) {
+
+	prevLoc := loc
+	defer func() { loc = prevLoc }()
+	loc = time.UTC
+
+	tests := []struct {
+		desc string
+		rec  RecurrenceT
+		date string
+		want string
+	}{
+		{"working day", RecurrenceT{}, "2026-05-05 10:00", "2026-05-05 10:00"},
+		{"weekend - not moved", RecurrenceT{}, "2026-05-02 10:00", "2026-05-02 10:00"},
+		{"holiday on Thursday", RecurrenceT{}, "2026-05-14 10:00", "2026-05-15 10:00"},
+		{"holiday on Friday - not Saturday", RecurrenceT{}, "2026-05-01 10:00", "2026-05-04 10:00"},
+		{"Good Friday - past Easter Monday", RecurrenceT{}, "2026-04-03 08:00", "2026-04-07 08:00"},
+		{"Christmas on Friday - holiday on Saturday", RecurrenceT{}, "2026-12-25 09:00", "2026-12-28 09:00"},
+		{"extra holiday on Friday", RecurrenceT{ExtraHolidays: []string{"2026-07-17"}}, "2026-07-17 17:00", "2026-07-20 17:00"},
+		{"extra holiday before federal holiday", RecurrenceT{ExtraHolidays: []string{"2026-12-24"}}, "2026-12-24 09:00", "2026-12-28 09:00"},
+		{"state holiday", RecurrenceT{Holidays: "de-bw"}, "2026-01-06 09:00", "2026-01-07 09:00"},
+		{"state holiday - federal only", RecurrenceT{}, "2026-01-06 09:00", "2026-01-06 09:00"},
+		{"none", RecurrenceT{Holidays: "none"}, "2026-05-01 10:00", "2026-05-01 10:00"},
+	}
+	for _, tt := range tests {
+		d, _ := time.ParseInLocation("2006-01-02 15:04", tt.date, loc)
+		got := tt.rec.skipHolidays(d).Format("2006-01-02 15:04")
+		if got != tt.want {
+			t.Errorf("%v: %v => %v; want %v", tt.desc, tt.date, got, tt.want)
+		}
+	}
+}
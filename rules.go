@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// RuleT derives recipient fields - declared in config.json
+// for all projects, per project or per task.
+//
+//	{
+//	  "when":    ["SourceTable == pds-old"],
+//	  "set":     {"Language": "en"},
+//	  "no_mail": true
+//	}
+//
+// Rules are evaluated in this order:
+//
+//	phase "input"   - global, project, task rules
+//	                - language fallback, salutation, exclusions
+//	                - dates, quarters, unsubscribe links
+//	phase "derived" - global, project, task rules
+//
+// Within a phase, rules are evaluated in config order;
+// later rules see the results of earlier rules.
+type RuleT struct {
+	Description string `json:"description,omitempty"`
+
+	// "input" - default - or "derived"
+	Phase string `json:"phase,omitempty"`
+
+	// all conditions must hold; field names as in Recipient, CSV column names or arbitrary CSV columns;
+	// operators == and != compare, ~ and !~ test for substrings;
+	// "Skip != " means non-empty
+	When []string `json:"when,omitempty"`
+
+	// field => value; values are text/templates, executed with recipient, wave and publication date;
+	// all values are computed before assignment
+	Set map[string]string `json:"set,omitempty"`
+
+	NoMail bool `json:"no_mail,omitempty"`
+
+	// keep CSV column anrede - no salutation rules
+	KeepAnrede bool `json:"keep_anrede,omitempty"`
+}
+
+// ruleDataT is passed to Set templates
+type ruleDataT struct {
+	*Recipient
+	Wave        WaveT
+	Publication time.Time // day after ClosingDateLastDue
+}
+
+var ruleCondRe = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*?)\s*(==|!=|!~|~)\s*(.*?)\s*$`)
+
+var ruleFuncs = template.FuncMap{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"contains": func(s, substr string) bool {
+		return strings.Contains(s, substr)
+	},
+}
+
+// ruleField finds a struct field by Go name or csv tag
+func ruleField(rec *Recipient, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(rec).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Name == name || f.Tag.Get("csv") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func ruleGet(rec *Recipient, name string) string {
+	if fv, ok := ruleField(rec, name); ok {
+		return fmt.Sprint(fv.Interface())
+	}
+	return rec.Fields[name]
+}
+
+func ruleSet(rec *Recipient, name, val string) error {
+	fv, ok := ruleField(rec, name)
+	if !ok {
+		if rec.Fields == nil {
+			rec.Fields = map[string]string{}
+		}
+		rec.Fields[name] = val
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Int:
+		i, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return fmt.Errorf("field %v requires an integer; %w", name, err)
+		}
+		fv.SetInt(int64(i))
+	default:
+		return fmt.Errorf("field %v cannot be set by rules", name)
+	}
+	return nil
+}
+
+// matches checks all conditions
+func (r RuleT) matches(rec *Recipient) (bool, error) {
+	for _, cond := range r.When {
+		m := ruleCondRe.FindStringSubmatch(cond)
+		if m == nil {
+			return false, fmt.Errorf("invalid condition %q", cond)
+		}
+		val := ruleGet(rec, m[1])
+		ok := false
+		switch m[2] {
+		case "==":
+			ok = val == m[3]
+		case "!=":
+			ok = val != m[3]
+		case "~":
+			ok = strings.Contains(val, m[3])
+		case "!~":
+			ok = !strings.Contains(val, m[3])
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+var ruleTpls = map[string]*template.Template{}
+var ruleMtx sync.Mutex
+
+func ruleTpl(src string) (*template.Template, error) {
+	ruleMtx.Lock()
+	defer ruleMtx.Unlock()
+	if t, ok := ruleTpls[src]; ok {
+		return t, nil
+	}
+	t, err := template.New("rule").Funcs(ruleFuncs).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	ruleTpls[src] = t
+	return t, nil
+}
+
+// apply evaluates a single rule
+func (r RuleT) apply(rec *Recipient, wv WaveT) error {
+
+	ok, err := r.matches(rec)
+	if err != nil || !ok {
+		return err
+	}
+
+	data := ruleDataT{
+		Recipient:   rec,
+		Wave:        wv,
+		Publication: wv.ClosingDateLastDue.AddDate(0, 0, 1),
+	}
+	vals := map[string]string{}
+	for name, src := range r.Set {
+		t, err := ruleTpl(src)
+		if err != nil {
+			return err
+		}
+		sb := &strings.Builder{}
+		if err := t.Execute(sb, data); err != nil {
+			return fmt.Errorf("rule %q - field %v: %w", r.Description, name, err)
+		}
+		vals[name] = sb.String()
+	}
+	names := make([]string, 0, len(vals))
+	for name := range vals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ruleSet(rec, name, vals[name]); err != nil {
+			return fmt.Errorf("rule %q: %w", r.Description, err)
+		}
+	}
+
+	if r.NoMail && !strings.Contains(rec.NoMail, "noMail") {
+		rec.NoMail += " noMail"
+	}
+	if r.KeepAnrede {
+		rec.keepAnrede = true
+	}
+	return nil
+}
+
+// applyRules evaluates global, project and task rules of a phase
+func applyRules(phase, project string, wv WaveT, tsk TaskT, rec *Recipient) error {
+	for _, rules := range [][]RuleT{cfg.Rules, cfg.Projects[project].Rules, tsk.Rules} {
+		for _, r := range rules {
+			if r.Phase == "" && phase != "input" || r.Phase != "" && r.Phase != phase {
+				continue
+			}
+			if err := r.apply(rec, wv); err != nil {
+				return fmt.Errorf("%v - %v: %w", project, tsk.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules validates conditions and templates of all rules
+func checkRules() error {
+	check := func(where string, rules []RuleT) error {
+		for _, r := range rules {
+			if r.Phase != "" && r.Phase != "input" && r.Phase != "derived" {
+				return fmt.Errorf("%v: rule %q: phase must be 'input' or 'derived'", where, r.Description)
+			}
+			for _, cond := range r.When {
+				if !ruleCondRe.MatchString(cond) {
+					return fmt.Errorf("%v: rule %q: invalid condition %q", where, r.Description, cond)
+				}
+			}
+			for name, src := range r.Set {
+				if _, err := ruleTpl(src); err != nil {
+					return fmt.Errorf("%v: rule %q - field %v: %w", where, r.Description, name, err)
+				}
+			}
+		}
+		return nil
+	}
+	if err := check("global", cfg.Rules); err != nil {
+		return err
+	}
+	for project, prj := range cfg.Projects {
+		if err := check(project, prj.Rules); err != nil {
+			return err
+		}
+	}
+	for project, tsks := range cfg.Tasks {
+		for _, tsk := range tsks {
+			if err := check(project+"-"+tsk.Name, tsk.Rules); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRuleMatches(t *testing.T) {
+	rec := &Recipient{
+		Language:    "de",
+		SourceTable: "pds-old",
+		Sex:         2,
+		Fields:      map[string]string{"segment": "bank", "src_table": "pds-old"},
+	}
+	tests := []struct {
+		when    []string
+		want    bool
+		wantErr bool
+	}{
+		{nil, true, false},
+		{[]string{"Language == de"}, true, false},
+		{[]string{"lang == de"}, true, false}, // csv tag
+		{[]string{"Language == en"}, false, false},
+		{[]string{"Language != en"}, true, false},
+		{[]string{"SourceTable ~ old"}, true, false},
+		{[]string{"SourceTable !~ old"}, false, false},
+		{[]string{"Sex == 2"}, true, false},
+		{[]string{"Skip != "}, false, false}, // non-empty
+		{[]string{"Skip == "}, true, false},
+		{[]string{"segment == bank"}, true, false}, // arbitrary csv column
+		{[]string{"missing == "}, true, false},
+		{[]string{"Language == de", "segment == insurance"}, false, false}, // all must hold
+		{[]string{"Language de"}, false, true},
+	}
+	for _, tt := range tests {
+		got, err := RuleT{When: tt.when}.matches(rec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v; want error %v", tt.when, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: matches %v; want %v", tt.when, got, tt.want)
+		}
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		val     string
+		get     func(*Recipient) string
+		want    string
+		wantErr bool
+	}{
+		{"Language", "en", func(r *Recipient) string { return r.Language }, "en", false},
+		{"lang", "fr", func(r *Recipient) string { return r.Language }, "fr", false},
+		{"Link", "https://x/?a=1&b=2", func(r *Recipient) string { return string(r.Link) }, "https://x/?a=1&b=2", false},
+		{"Sex", " 1 ", func(r *Recipient) string { return ruleGet(r, "Sex") }, "1", false},
+		{"Sex", "female", nil, "", true},
+		{"segment", "bank", func(r *Recipient) string { return r.Fields["segment"] }, "bank", false},
+	}
+	for _, tt := range tests {
+		rec := &Recipient{}
+		err := ruleSet(rec, tt.name, tt.val)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v=%q: error %v; want error %v", tt.name, tt.val, err, tt.wantErr)
+			continue
+		}
+		if tt.get != nil && tt.get(rec) != tt.want {
+			t.Errorf("%v=%q: got %q; want %q", tt.name, tt.val, tt.get(rec), tt.want)
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+
+	prevCfg := cfg
+	defer func() { cfg = prevCfg }()
+
+	wv := WaveT{
+		Year:               2026,
+		Month:              time.July,
+		ClosingDateLastDue: time.Date(2026, 7, 20, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		desc    string
+		global  []RuleT
+		project []RuleT
+		task    []RuleT
+		phase   string
+		rec     Recipient
+		check   func(Recipient) string // returns a description of the failure
+	}{
+		{
+			desc:  "phase input is default",
+			task:  []RuleT{{Set: map[string]string{"Language": "en"}}},
+			phase: "input",
+			check: func(r Recipient) string { return expect("Language", r.Language, "en") },
+		},
+		{
+			desc:  "derived rules are skipped in phase input",
+			task:  []RuleT{{Phase: "derived", Set: map[string]string{"Language": "en"}}},
+			phase: "input",
+			rec:   Recipient{Language: "de"},
+			check: func(r Recipient) string { return expect("Language", r.Language, "de") },
+		},
+		{
+			desc:  "input rules are skipped in phase derived",
+			task:  []RuleT{{Set: map[string]string{"Language": "en"}}},
+			phase: "derived",
+			rec:   Recipient{Language: "de"},
+			check: func(r Recipient) string { return expect("Language", r.Language, "de") },
+		},
+		{
+			desc:    "global, project, task - the last assignment wins",
+			global:  []RuleT{{Set: map[string]string{"Language": "de"}}},
+			project: []RuleT{{Set: map[string]string{"Language": "en"}}},
+			task:    []RuleT{{Set: map[string]string{"Language": "fr"}}},
+			phase:   "input",
+			check:   func(r Recipient) string { return expect("Language", r.Language, "fr") },
+		},
+		{
+			desc: "later rules see the results of earlier rules",
+			project: []RuleT{
+				{When: []string{"SourceTable == pds-old"}, Set: map[string]string{"Language": "en"}},
+				{When: []string{"Language == en"}, Set: map[string]string{"Link": "https://x/{{.Language}}/{{.ID}}"}},
+			},
+			phase: "input",
+			rec:   Recipient{ID: "7", Language: "de", SourceTable: "pds-old"},
+			check: func(r Recipient) string { return expect("Link", string(r.Link), "https://x/en/7") },
+		},
+		{
+			desc: "earlier rules do not see the results of later rules",
+			project: []RuleT{
+				{When: []string{"Language == en"}, Set: map[string]string{"Link": "https://x/en"}},
+				{Set: map[string]string{"Language": "en"}},
+			},
+			phase: "input",
+			rec:   Recipient{Language: "de"},
+			check: func(r Recipient) string { return expect("Link", string(r.Link), "") },
+		},
+		{
+			desc: "all values are computed before assignment",
+			task: []RuleT{{Set: map[string]string{"Firstname": "{{.Lastname}}", "Lastname": "{{.Firstname}}"}}},
+			rec:  Recipient{Firstname: "Jane", Lastname: "Doe"},
+			check: func(r Recipient) string {
+				return expect("Firstname", r.Firstname, "Doe") + expect("Lastname", r.Lastname, "Jane")
+			},
+		},
+		{
+			desc:  "no_mail on non-empty skip",
+			task:  []RuleT{{When: []string{"Skip != "}, NoMail: true}},
+			rec:   Recipient{Skip: "refused"},
+			check: func(r Recipient) string { return expect("NoMail", r.NoMail, " noMail") },
+		},
+		{
+			desc:  "no_mail not set twice",
+			task:  []RuleT{{NoMail: true}, {NoMail: true}},
+			rec:   Recipient{NoMail: "noMail"},
+			check: func(r Recipient) string { return expect("NoMail", r.NoMail, "noMail") },
+		},
+		{
+			desc:  "wave and publication date in templates",
+			task:  []RuleT{{Phase: "derived", Set: map[string]string{"pub": `{{.Wave.Year}} {{.Publication.Format "2006-01-02"}}`}}},
+			phase: "derived",
+			check: func(r Recipient) string { return expect("pub", r.Fields["pub"], "2026 2026-07-21") },
+		},
+	}
+
+	for _, tt := range tests {
+		cfg = configT{
+			Rules:    tt.global,
+			Projects: map[string]ProjectT{"p": {Rules: tt.project}},
+		}
+		phase := tt.phase
+		if phase == "" {
+			phase = "input"
+		}
+		rec := tt.rec
+		if err := applyRules(phase, "p", wv, TaskT{Name: "t", Rules: tt.task}, &rec); err != nil {
+			t.Errorf("%v: %v", tt.desc, err)
+			continue
+		}
+		if msg := tt.check(rec); msg != "" {
+			t.Errorf("%v: %v", tt.desc, msg)
+		}
+	}
+}
+
+func expect(field, got, want string) string {
+	if got != want {
+		return fmt.Sprintf("%v = %q; want %q ", field, got, want)
+	}
+	return ""
+}
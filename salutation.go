@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// SalutationT computes the salutation - Anrede - for a language.
+// The texts are templates executed with the recipient;
+// multiple spaces - i.e. from empty titles - are collapsed.
+//
+// Precedence:
+// CSV anrede (if kept), title, sex, name, organization mailbox, fallback.
+// Title, sex and name require a last name; name also requires first name or title.
+// Thus role mailboxes - info@ - get the organization salutation only without personal data.
+type SalutationT struct {
+	// keep non-empty CSV column anrede
+	KeepCSV bool `json:"keep_csv_anrede,omitempty"`
+
+	// sex derived from CSV column anrede, if sex column is zero; i.e. "Mr." => 1
+	SexFromAnrede map[string]int `json:"sex_from_anrede,omitempty"`
+
+	// academic titles are reordered accordingly; i.e. "Dr. Prof." => "Prof. Dr."
+	TitleOrder []string `json:"title_order,omitempty"`
+
+	// for recipients with title - regardless of sex; i.e. "Dear {{.Title}} {{.Lastname}}"
+	Title string `json:"title,omitempty"`
+
+	// by sex code - 1 male, 2 female, 3 diverse, 0 unknown
+	Sex map[string]string `json:"sex,omitempty"`
+
+	// gender neutral - for diverse or unknown sex; i.e. "Dear {{.Firstname}} {{.Lastname}}"
+	Name string `json:"name,omitempty"`
+
+	// for role mailboxes - i.e. info@ or kontakt@ company addresses; defaults to fallback
+	Organization string `json:"organization,omitempty"`
+	// local parts of role mailboxes; default organizationMailboxes
+	OrganizationMailboxes []string `json:"organization_mailboxes,omitempty"`
+
+	// i.e. "Dear Sir or Madam"
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// built-in salutations - for projects without config
+var defaultSalutations = map[string]SalutationT{
+	"de": {
+		TitleOrder: []string{"Prof.", "Dr."},
+		Sex: map[string]string{
+			"1": "Sehr geehrter Herr {{.Title}} {{.Lastname}}",
+			"2": "Sehr geehrte Frau {{.Title}} {{.Lastname}}",
+		},
+		Name:     "Guten Tag {{.Title}} {{.Firstname}} {{.Lastname}}",
+		Fallback: "Sehr geehrte Damen und Herren",
+	},
+	"en": {
+		TitleOrder: []string{"Prof.", "Dr."},
+		Title:      "Dear {{.Title}} {{.Lastname}}",
+		Sex: map[string]string{
+			"1": "Dear Mr. {{.Lastname}}",
+			"2": "Dear Ms. {{.Lastname}}",
+		},
+		Name:     "Dear {{.Firstname}} {{.Lastname}}",
+		Fallback: "Dear Sir or Madam",
+	},
+}
+
+// organizationMailboxes are local parts of role addresses - info@, info.mannheim@
+var organizationMailboxes = []string{
+	"info", "kontakt", "contact", "office", "mail", "post", "poststelle",
+	"service", "sekretariat", "verwaltung", "vertrieb", "sales",
+	"zentrale", "empfang", "buero", "team", "hello", "rathaus", "gemeinde", "stadt",
+}
+
+// isOrganizationMailbox checks the local part of the email
+func isOrganizationMailbox(email string, mailboxes []string) bool {
+	if len(mailboxes) == 0 {
+		mailboxes = organizationMailboxes
+	}
+	local, _, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return false
+	}
+	for _, mb := range mailboxes {
+		if local == mb {
+			return true
+		}
+		for _, sep := range []string{".", "-", "_"} {
+			if strings.HasPrefix(local, mb+sep) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// salutationRules returns the project rules for a language
+// or the built-in rules
+func salutationRules(project, lang string) (SalutationT, bool) {
+	if sal, ok := cfg.Projects[project].Salutations[lang]; ok {
+		return sal, true
+	}
+	sal, ok := defaultSalutations[lang]
+	return sal, ok
+}
+
+// orderTitles reorders known academic titles; unknown titles remain behind
+func orderTitles(title string, order []string) string {
+	known, unknown := []string{}, []string{}
+	for _, t := range strings.Fields(title) {
+		if slices.Contains(order, t) {
+			if !slices.Contains(known, t) {
+				known = append(known, t)
+			}
+		} else {
+			unknown = append(unknown, t)
+		}
+	}
+	slices.SortStableFunc(known, func(a, b string) int {
+		return slices.Index(order, a) - slices.Index(order, b)
+	})
+	return strings.Join(append(known, unknown...), " ")
+}
+
+var salutationTpls = map[string]*template.Template{}
+var salutationMtx sync.Mutex
+
+func salutationTpl(src string) (*template.Template, error) {
+	salutationMtx.Lock()
+	defer salutationMtx.Unlock()
+	if t, ok := salutationTpls[src]; ok {
+		return t, nil
+	}
+	t, err := template.New("salutation").Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	salutationTpls[src] = t
+	return t, nil
+}
+
+func execSalutation(src string, rec Recipient) (string, error) {
+	t, err := salutationTpl(src)
+	if err != nil {
+		return "", err
+	}
+	sb := &strings.Builder{}
+	if err := t.Execute(sb, rec); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(sb.String()), " "), nil
+}
+
+// salutation kinds - all but salutCSV, salutTitle and salutSex are reported in preflight
+const (
+	salutCSV          = "csv"
+	salutTitle        = "title"
+	salutSex          = "sex"
+	salutName         = "name"
+	salutOrganization = "organization"
+	salutFallback     = "fallback"
+	salutNone         = "none"
+)
+
+// salutation returns the salutation for the recipient language
+// and the kind of rule applied; false, if there are no rules for the language
+func salutation(project string, rec Recipient) (string, string, bool) {
+
+	sal, ok := salutationRules(project, rec.Language)
+	if !ok {
+		return rec.Anrede, salutNone, false
+	}
+
+	rec.Anrede = strings.TrimSpace(rec.Anrede)
+	rec.Firstname = strings.TrimSpace(rec.Firstname)
+	rec.Lastname = strings.TrimSpace(rec.Lastname)
+	rec.Title = orderTitles(rec.Title, sal.TitleOrder)
+
+	if rec.Sex == 0 {
+		if sex, ok := sal.SexFromAnrede[rec.Anrede]; ok {
+			rec.Sex = sex
+		}
+	}
+	_, anredeIsSex := sal.SexFromAnrede[rec.Anrede]
+
+	if sal.KeepCSV && rec.Anrede != "" && !anredeIsSex {
+		return rec.Anrede, salutCSV, true
+	}
+
+	type candidate struct{ kind, src string }
+	candidates := []candidate{}
+	if rec.Lastname != "" {
+		if rec.Title != "" && sal.Title != "" {
+			candidates = append(candidates, candidate{salutTitle, sal.Title})
+		}
+		if src, ok := sal.Sex[strconv.Itoa(rec.Sex)]; ok {
+			candidates = append(candidates, candidate{salutSex, src})
+		}
+		if sal.Name != "" && (rec.Firstname != "" || rec.Title != "") {
+			candidates = append(candidates, candidate{salutName, sal.Name})
+		}
+	}
+	if isOrganizationMailbox(rec.Email, sal.OrganizationMailboxes) {
+		if sal.Organization != "" {
+			candidates = append(candidates, candidate{salutOrganization, sal.Organization})
+		} else {
+			candidates = append(candidates, candidate{salutOrganization, sal.Fallback})
+		}
+	}
+	candidates = append(candidates, candidate{salutFallback, sal.Fallback})
+
+	for _, c := range candidates {
+		s, err := execSalutation(c.src, rec)
+		if err != nil {
+			log.Printf("salutation %q for %v: %v", c.src, rec.Email, err)
+			continue
+		}
+		if s != "" {
+			return s, c.kind, true
+		}
+	}
+	return "", salutNone, true
+}
+
+// logSalutationFallbacks lists recipients without personal salutation - in preflight
+func logSalutationFallbacks(recs []*Recipient) {
+	cnts := map[string]int{}
+	for _, rec := range recs {
+		if strings.Contains(rec.NoMail, "noMail") {
+			continue
+		}
+		switch rec.salutationKind {
+		case salutName, salutOrganization, salutFallback, salutNone:
+			cnts[rec.salutationKind]++
+			if cnts[rec.salutationKind] > 20 {
+				continue // i.e. thousands of company mailboxes
+			}
+			log.Printf("    salutation %-12v %v - %q", rec.salutationKind, rec, rec.Anrede)
+		}
+	}
+	for _, kind := range []string{salutName, salutOrganization, salutFallback, salutNone} {
+		if cnts[kind] > 0 {
+			log.Printf("  salutation %-12v %4d recipient(s)", kind, cnts[kind])
+		}
+	}
+}
+
+// checkSalutations parses all configured salutation templates
+func checkSalutations() error {
+	for project, prj := range cfg.Projects {
+		for lang, sal := range prj.Salutations {
+			srcs := []string{sal.Title, sal.Name, sal.Organization, sal.Fallback}
+			for _, src := range sal.Sex {
+				srcs = append(srcs, src)
+			}
+			for _, src := range srcs {
+				if _, err := salutationTpl(src); err != nil {
+					return fmt.Errorf("project %v - salutation %v: %w", project, lang, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestSalutation(t *testing.T) {
+
+	prevCfg := cfg
+	defer func() { cfg = prevCfg }()
+	cfg = configT{Projects: map[string]ProjectT{
+		"p": {},
+		"org": {Salutations: map[string]SalutationT{
+			"de": {
+				Sex:          map[string]string{"1": "Sehr geehrter Herr {{.Lastname}}"},
+				Organization: "Sehr geehrte Damen und Herren der {{.Firstname}}",
+				Fallback:     "Guten Tag",
+			},
+		}},
+	}}
+
+	tests := []struct {
+		project  string
+		rec      Recipient
+		want     string
+		wantKind string
+	}{
+		{"p", Recipient{Language: "de", Sex: 1, Lastname: "Müller", Email: "m@example.com"}, "Sehr geehrter Herr Müller", salutSex},
+		{"p", Recipient{Language: "de", Sex: 2, Title: "Dr. Prof.", Lastname: "Weber"}, "Sehr geehrte Frau Prof. Dr. Weber", salutSex},
+		{"p", Recipient{Language: "en", Sex: 3, Title: "Dr.", Lastname: "Weber"}, "Dear Dr. Weber", salutTitle},
+		{"p", Recipient{Language: "de", Sex: 3, Firstname: "Kim", Lastname: "Weber"}, "Guten Tag Kim Weber", salutName},
+		{"p", Recipient{Language: "de", Sex: 0, Lastname: "Weber"}, "Sehr geehrte Damen und Herren", salutFallback},
+		{"p", Recipient{Language: "de", Sex: 1}, "Sehr geehrte Damen und Herren", salutFallback},
+
+		// role mailboxes - personal salutation, if lastname and sex are known
+		{"p", Recipient{Language: "de", Sex: 1, Lastname: "Müller", Email: "info@example.com"}, "Sehr geehrter Herr Müller", salutSex},
+		{"p", Recipient{Language: "en", Sex: 2, Lastname: "Smith", Email: "post.mannheim@example.com"}, "Dear Ms. Smith", salutSex},
+		{"p", Recipient{Language: "de", Sex: 3, Firstname: "Kim", Lastname: "Weber", Email: "mail.x@example.com"}, "Guten Tag Kim Weber", salutName},
+		{"p", Recipient{Language: "de", Email: "info@example.com"}, "Sehr geehrte Damen und Herren", salutOrganization},
+		{"org", Recipient{Language: "de", Firstname: "ACME AG", Email: "kontakt@acme.de"}, "Sehr geehrte Damen und Herren der ACME AG", salutOrganization},
+		{"org", Recipient{Language: "de", Sex: 1, Lastname: "Müller", Email: "kontakt@acme.de"}, "Sehr geehrter Herr Müller", salutSex},
+		{"org", Recipient{Language: "de", Sex: 2, Lastname: "Müller", Email: "kontakt@acme.de"}, "Sehr geehrte Damen und Herren der", salutOrganization},
+		{"org", Recipient{Language: "de", Sex: 2, Lastname: "Müller", Email: "m@acme.de"}, "Guten Tag", salutFallback},
+	}
+	for _, tt := range tests {
+		got, kind, _ := salutation(tt.project, tt.rec)
+		if got != tt.want || kind != tt.wantKind {
+			t.Errorf("%v %+v: %q %v; want %q %v", tt.project, tt.rec, got, kind, tt.want, tt.wantKind)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedT is a parsed ExecutionInterval - cron expression or friendly form;
+// evaluated in the task location - or the configured location.
+//
+//	"30 10 * * 1-5"                    minute hour day-of-month month day-of-week
+//	"every weekday 10:30"
+//	"every day 06:00"                  "every monday,thursday 09:00"
+//	"first monday of month 11:00"      "last friday of month 17:00"
+//	"monthly on day 5 09:00"           "quarterly on day 5 09:00"
+//	"hourly"
+//	"daily"                            legacy - every run
+type schedT struct {
+	src    string
+	always bool // legacy "daily"
+
+	minutes, hours, doms, months, dows uint64 // bitsets
+	domStar, dowStar                   bool
+
+	nth int // 1..4 or -1 for last; restricts dows to the nth weekday of the month
+
+	loc *time.Location // nil means global loc
+}
+
+var schedMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var schedDowNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// cronField parses "*", "5", "1-5", "*/15", "1,15", "mon-fri"
+func cronField(fld string, min, max int, names map[string]int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(strings.ToLower(fld), ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(loStr, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(hiStr, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %v-%v", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[s]; ok {
+		return v, nil
+	}
+	if len(s) > 3 {
+		if v, ok := names[s[:3]]; ok {
+			return v, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+var (
+	schedTimeRe    = regexp.MustCompile(`^(.*?)\s*(?:at\s+)?(\d{1,2}):(\d{2})$`)
+	schedEveryRe   = regexp.MustCompile(`^every\s+([a-z,\s]+)$`)
+	schedNthRe     = regexp.MustCompile(`^(first|second|third|fourth|last)\s+([a-z]+)\s+of\s+(?:the\s+)?month$`)
+	schedOnDayRe   = regexp.MustCompile(`^(monthly|quarterly)\s+on\s+day\s+(\d{1,2})$`)
+	schedWeekdayRe = regexp.MustCompile(`^[a-z]+$`)
+)
+
+// parseInterval parses cron expressions and friendly forms
+func parseInterval(src string) (*schedT, error) {
+
+	s := strings.ToLower(strings.Join(strings.Fields(src), " "))
+	if s == "daily" {
+		return &schedT{src: src, always: true}, nil
+	}
+
+	if flds := strings.Fields(s); len(flds) == 5 && !schedWeekdayRe.MatchString(flds[0]) {
+		return parseCron(src, flds)
+	}
+
+	// clock time
+	hh, mm := "0", "0"
+	if m := schedTimeRe.FindStringSubmatch(s); m != nil {
+		s, hh, mm = m[1], m[2], m[3]
+	}
+
+	var flds []string
+	switch {
+	case s == "hourly":
+		flds = []string{"0", "*", "*", "*", "*"}
+	case s == "every day" || s == "daily":
+		flds = []string{mm, hh, "*", "*", "*"}
+	case s == "every weekday":
+		flds = []string{mm, hh, "*", "*", "1-5"}
+	case schedNthRe.MatchString(s):
+		m := schedNthRe.FindStringSubmatch(s)
+		sc, err := parseCron(src, []string{mm, hh, "*", "*", m[2]})
+		if err != nil {
+			return nil, err
+		}
+		sc.nth = recurOrdinals[m[1]]
+		return sc, nil
+	case schedOnDayRe.MatchString(s):
+		m := schedOnDayRe.FindStringSubmatch(s)
+		months := "*"
+		if m[1] == "quarterly" {
+			months = "1,4,7,10"
+		}
+		flds = []string{mm, hh, m[2], months, "*"}
+	case schedEveryRe.MatchString(s):
+		m := schedEveryRe.FindStringSubmatch(s)
+		days := strings.ReplaceAll(strings.ReplaceAll(m[1], " and ", ","), " ", "")
+		flds = []string{mm, hh, "*", "*", days}
+	default:
+		return nil, fmt.Errorf("interval %q: neither cron expression nor known form", src)
+	}
+	return parseCron(src, flds)
+}
+
+func parseCron(src string, flds []string) (*schedT, error) {
+	sc := &schedT{src: src}
+	var err error
+	if sc.minutes, err = cronField(flds[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("interval %q - minute: %w", src, err)
+	}
+	if sc.hours, err = cronField(flds[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("interval %q - hour: %w", src, err)
+	}
+	if sc.doms, err = cronField(flds[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("interval %q - day of month: %w", src, err)
+	}
+	if sc.months, err = cronField(flds[3], 1, 12, schedMonthNames); err != nil {
+		return nil, fmt.Errorf("interval %q - month: %w", src, err)
+	}
+	if sc.dows, err = cronField(flds[4], 0, 7, schedDowNames); err != nil {
+		return nil, fmt.Errorf("interval %q - day of week: %w", src, err)
+	}
+	if bit(sc.dows, 7) { // Sunday as 7
+		sc.dows = sc.dows&^(1<<7) | 1
+	}
+	sc.domStar = flds[2] == "*"
+	sc.dowStar = flds[4] == "*"
+	return sc, nil
+}
+
+// taskInterval parses the interval of a task in the task location
+func taskInterval(tsk TaskT) (*schedT, error) {
+	sc, err := parseInterval(tsk.ExecutionInterval)
+	if err != nil {
+		return nil, err
+	}
+	sc.loc = tsk.location
+	return sc, nil
+}
+
+func (sc *schedT) location() *time.Location {
+	if sc.loc != nil {
+		return sc.loc
+	}
+	return loc
+}
+
+func bit(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// matchDay - cron semantics: day of month OR day of week, if both are restricted
+func (sc *schedT) matchDay(d time.Time) bool {
+	if !bit(sc.months, int(d.Month())) {
+		return false
+	}
+	if sc.nth != 0 {
+		if !bit(sc.dows, int(d.Weekday())) {
+			return false
+		}
+		if sc.nth > 0 {
+			return (d.Day()-1)/7+1 == sc.nth
+		}
+		return d.AddDate(0, 0, 7).Month() != d.Month()
+	}
+	dom := bit(sc.doms, d.Day())
+	dow := bit(sc.dows, int(d.Weekday()))
+	switch {
+	case sc.domStar && sc.dowStar:
+		return true
+	case sc.domStar:
+		return dow
+	case sc.dowStar:
+		return dom
+	}
+	return dom || dow
+}
+
+// clockTimes returns hours and minutes of a day in ascending order
+func (sc *schedT) clockTimes() [][2]int {
+	cts := [][2]int{}
+	for h := 0; h < 24; h++ {
+		if !bit(sc.hours, h) {
+			continue
+		}
+		for m := 0; m < 60; m++ {
+			if bit(sc.minutes, m) {
+				cts = append(cts, [2]int{h, m})
+			}
+		}
+	}
+	return cts
+}
+
+// schedHorizon limits the search - i.e. for Feb 30
+const schedHorizon = 5 * 366
+
+// Next returns the first fire time after t; zero time if none
+func (sc *schedT) Next(t time.Time) time.Time {
+	loc := sc.location()
+	t = t.In(loc)
+	cts := sc.clockTimes()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	for i := 0; i < schedHorizon; i++ {
+		d := day.AddDate(0, 0, i)
+		if !sc.matchDay(d) {
+			continue
+		}
+		for _, ct := range cts {
+			ft := time.Date(d.Year(), d.Month(), d.Day(), ct[0], ct[1], 0, 0, loc)
+			if ft.After(t) {
+				return ft
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Prev returns the last fire time at or before t; zero time if none
+func (sc *schedT) Prev(t time.Time) time.Time {
+	loc := sc.location()
+	t = t.In(loc)
+	cts := sc.clockTimes()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	for i := 0; i < schedHorizon; i++ {
+		d := day.AddDate(0, 0, -i)
+		if !sc.matchDay(d) {
+			continue
+		}
+		for j := len(cts) - 1; j >= 0; j-- {
+			ft := time.Date(d.Year(), d.Month(), d.Day(), cts[j][0], cts[j][1], 0, 0, loc)
+			if !ft.After(t) {
+				return ft
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// String describes the parsed schedule
+func (sc *schedT) String() string {
+	if sc.always {
+		return sc.src + " - every run"
+	}
+	return sc.src
+}
+
+// intervalDisabled - "xx-daily" - as keys prefixed xx- in config.json
+func intervalDisabled(interval string) bool {
+	return interval == "" || strings.HasPrefix(interval, "xx-")
+}
+
+// explainSchedules logs the next n fire times of tasks with interval or execution time;
+// expr - an arbitrary interval instead of the configured tasks
+func explainSchedules(project, task, expr string, n int) error {
+
+	if expr != "" {
+		sc, err := parseInterval(expr)
+		if err != nil {
+			return err
+		}
+		explainSchedule("interval", "", TaskT{}, sc, n)
+		return nil
+	}
+
+	projects := make([]string, 0, len(cfg.Tasks))
+	for prj := range cfg.Tasks {
+		projects = append(projects, prj)
+	}
+	sort.Strings(projects)
+
+	for _, prj := range projects {
+		if project != "" && prj != project {
+			continue
+		}
+		for _, tsk := range cfg.Tasks[prj] {
+			if task != "" && tsk.Name != task {
+				continue
+			}
+			label := fmt.Sprintf("%v-%v", prj, tsk.Name)
+			if intervalDisabled(tsk.ExecutionInterval) {
+				if !tsk.ExecutionTime.IsZero() {
+					t, note := blackoutTime(prj, tsk, tsk.ExecutionTime)
+					if t.IsZero() {
+						t = tsk.ExecutionTime // blocked
+					}
+					log.Printf("%-32v at %v   %v", label, t.In(loc).Format("Mon 2006-01-02 15:04"), note)
+				}
+				continue
+			}
+			sc, err := taskInterval(tsk)
+			if err != nil {
+				return fmt.Errorf("%v: %w", label, err)
+			}
+			explainSchedule(label, prj, tsk, sc, n)
+		}
+	}
+	return nil
+}
+
+// explainSchedule - fire times shifted or blocked by the project blackout calendar are annotated
+func explainSchedule(label, project string, tsk TaskT, sc *schedT, n int) {
+	log.Printf("%-32v %v", label, sc)
+	if sc.always {
+		return
+	}
+	t := startTime
+	for i := 0; i < n; i++ {
+		t = sc.Next(t)
+		if t.IsZero() {
+			log.Printf("%-32v   no fire time within %v years", "", schedHorizon/366)
+			break
+		}
+		eff, note := blackoutTime(project, tsk, t)
+		if eff.IsZero() {
+			eff = t // blocked
+		}
+		log.Printf("%-32v   %v   %v", "", eff.Format("Mon 2006-01-02 15:04 MST"), note)
+	}
+}
+
+// checkIntervals parses all execution intervals
+func checkIntervals() error {
+	for project, tsks := range cfg.Tasks {
+		for _, tsk := range tsks {
+			if intervalDisabled(tsk.ExecutionInterval) {
+				continue
+			}
+			if _, err := parseInterval(tsk.ExecutionInterval); err != nil {
+				return fmt.Errorf("%v-%v: %w", project, tsk.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronField(t *testing.T) {
+
+	set := func(vs ...int) uint64 {
+		var s uint64
+		for _, v := range vs {
+			s |= 1 << uint(v)
+		}
+		return s
+	}
+
+	tests := []struct {
+		fld      string
+		min, max int
+		names    map[string]int
+		want     uint64
+		wantErr  bool
+	}{
+		{"*", 0, 6, nil, set(0, 1, 2, 3, 4, 5, 6), false},
+		{"5", 0, 59, nil, set(5), false},
+		{"0", 0, 59, nil, set(0), false},
+		{"59", 0, 59, nil, set(59), false},
+		{"60", 0, 59, nil, 0, true},
+		{"0", 1, 31, nil, 0, true}, // day of month starts at 1
+		{"31", 1, 31, nil, set(31), false},
+		{"32", 1, 31, nil, 0, true},
+		{"24", 0, 23, nil, 0, true},
+		{"13", 1, 12, schedMonthNames, 0, true},
+		{"1-5", 0, 7, nil, set(1, 2, 3, 4, 5), false},
+		{"5-1", 0, 7, nil, 0, true},
+		{"*/15", 0, 59, nil, set(0, 15, 30, 45), false},
+		{"10-30/10", 0, 59, nil, set(10, 20, 30), false},
+		{"5/20", 0, 59, nil, set(5, 25, 45), false}, // from 5 to max
+		{"*/0", 0, 59, nil, 0, true},
+		{"*/x", 0, 59, nil, 0, true},
+		{"1,15,31", 1, 31, nil, set(1, 15, 31), false},
+		{"1-3,10,20-21", 1, 31, nil, set(1, 2, 3, 10, 20, 21), false},
+		{"mon-fri", 0, 7, schedDowNames, set(1, 2, 3, 4, 5), false},
+		{"monday,thursday", 0, 7, schedDowNames, set(1, 4), false},
+		{"SAT,sun", 0, 7, schedDowNames, set(0, 6), false},
+		{"jan,apr,jul,oct", 1, 12, schedMonthNames, set(1, 4, 7, 10), false},
+		{"mon", 1, 12, schedMonthNames, 0, true}, // no month
+		{"x", 0, 59, nil, 0, true},
+		{"", 0, 59, nil, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := cronField(tt.fld, tt.min, tt.max, tt.names)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q %v-%v: error %v; want error %v", tt.fld, tt.min, tt.max, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q %v-%v: %b; want %b", tt.fld, tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr bool
+	}{
+		{"30 10 * * 1-5", false},
+		{"*/15 8-17 * * mon-fri", false},
+		{"0 9 1,15 * *", false},
+		{"0 9 * * 7", false}, // Sunday as 7
+		{"0 24 * * *", true},
+		{"60 10 * * *", true},
+		{"0 9 0 * *", true},
+		{"0 9 * 13 *", true},
+		{"0 9 * * 8", true},
+		{"0 9 * *", true}, // four fields - no friendly form either
+		{"every weekday 10:30", false},
+		{"every weekday at 10:30", false},
+		{"Every  Day 06:00", false},
+		{"every monday,thursday 09:00", false},
+		{"every monday and thursday 09:00", false},
+		{"first monday of month 11:00", false},
+		{"last friday of the month 17:00", false},
+		{"fifth friday of month 17:00", true},
+		{"monthly on day 5 09:00", false},
+		{"quarterly on day 5 09:00", false},
+		{"monthly on day 32 09:00", true},
+		{"hourly", false},
+		{"daily", false},
+		{"every blue moon", true},
+		{"every day 25:00", true},
+	}
+	for _, tt := range tests {
+		_, err := parseInterval(tt.src)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v; want error %v", tt.src, err, tt.wantErr)
+		}
+	}
+
+	sc, err := parseInterval("daily")
+	if err != nil || !sc.always {
+		t.Errorf("daily: legacy every run expected; %v", err)
+	}
+}
+
+func TestSchedNextPrev(t *testing.T) {
+
+	prevLoc := loc
+	defer func() { loc = prevLoc }()
+	var err error
+	if loc, err = time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Fatal(err)
+	}
+
+	const layout = "2006-01-02 15:04 MST"
+	parse := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		desc string
+		src  string
+		t    string
+		next string
+		prev string
+	}{
+		{"weekday - friday to monday", "every weekday 10:30", "2026-07-17 11:00", "2026-07-20 10:30 CEST", "2026-07-17 10:30 CEST"},
+		{"prev at fire time", "every weekday 10:30", "2026-07-17 10:30", "2026-07-20 10:30 CEST", "2026-07-17 10:30 CEST"},
+		{"weekend - prev friday", "30 10 * * 1-5", "2026-07-19 12:00", "2026-07-20 10:30 CEST", "2026-07-17 10:30 CEST"},
+		{"step minutes", "*/15 8-17 * * *", "2026-07-17 17:50", "2026-07-18 08:00 CEST", "2026-07-17 17:45 CEST"},
+		{"list of weekdays", "every monday,thursday 09:00", "2026-07-17 09:00", "2026-07-20 09:00 CEST", "2026-07-16 09:00 CEST"},
+		{"sunday as 7", "0 9 * * 7", "2026-07-17 09:00", "2026-07-19 09:00 CEST", "2026-07-12 09:00 CEST"},
+		{"dom or dow", "0 9 13 * fri", "2026-07-11 00:00", "2026-07-13 09:00 CEST", "2026-07-10 09:00 CEST"},
+		{"first monday", "first monday of month 11:00", "2026-07-07 00:00", "2026-08-03 11:00 CEST", "2026-07-06 11:00 CEST"},
+		{"second tuesday", "second tuesday of month 11:00", "2026-07-15 00:00", "2026-08-11 11:00 CEST", "2026-07-14 11:00 CEST"},
+		{"last friday", "last friday of month 17:00", "2026-07-25 00:00", "2026-07-31 17:00 CEST", "2026-06-26 17:00 CEST"},
+		{"last friday - february", "last friday of month 17:00", "2026-03-01 00:00", "2026-03-27 17:00 CET", "2026-02-27 17:00 CET"},
+		{"monthly - across month", "monthly on day 5 09:00", "2026-07-05 09:01", "2026-08-05 09:00 CEST", "2026-07-05 09:00 CEST"},
+		{"monthly - across year", "monthly on day 5 09:00", "2026-01-01 08:00", "2026-01-05 09:00 CET", "2025-12-05 09:00 CET"},
+		{"day 31 - short months skipped", "0 9 31 * *", "2026-04-01 00:00", "2026-05-31 09:00 CEST", "2026-03-31 09:00 CEST"},
+		{"quarterly", "quarterly on day 5 09:00", "2026-02-01 00:00", "2026-04-05 09:00 CEST", "2026-01-05 09:00 CET"},
+		{"quarterly - across year", "quarterly on day 5 09:00", "2026-12-01 00:00", "2027-01-05 09:00 CET", "2026-10-05 09:00 CEST"},
+		{"new year", "0 0 1 1 *", "2026-07-01 00:00", "2027-01-01 00:00 CET", "2026-01-01 00:00 CET"},
+		{"hourly", "hourly", "2026-07-17 10:30", "2026-07-17 11:00 CEST", "2026-07-17 10:00 CEST"},
+		{"february 30 - never", "0 9 30 2 *", "2026-01-01 00:00", "", ""},
+
+		// DST - clock time is kept across the transitions
+		{"spring forward - daily", "every day 10:30", "2026-03-28 11:00", "2026-03-29 10:30 CEST", "2026-03-28 10:30 CET"},
+		{"fall back - daily", "every day 10:30", "2026-10-25 09:00", "2026-10-25 10:30 CET", "2026-10-24 10:30 CEST"},
+		{"spring forward - skipped hour fires once", "30 2 * * *", "2026-03-29 01:00", "2026-03-29 03:30 CEST", "2026-03-28 02:30 CET"},
+		{"spring forward - after the skipped hour", "30 2 * * *", "2026-03-29 04:00", "2026-03-30 02:30 CEST", "2026-03-29 03:30 CEST"},
+	}
+	for _, tt := range tests {
+		sc, err := parseInterval(tt.src)
+		if err != nil {
+			t.Errorf("%v: %v", tt.desc, err)
+			continue
+		}
+		tm := parse(tt.t)
+		format := func(ft time.Time) string {
+			if ft.IsZero() {
+				return ""
+			}
+			return ft.Format(layout)
+		}
+		if got := format(sc.Next(tm)); got != tt.next {
+			t.Errorf("%v: %q Next(%v) = %q; want %q", tt.desc, tt.src, tt.t, got, tt.next)
+		}
+		if got := format(sc.Prev(tm)); got != tt.prev {
+			t.Errorf("%v: %q Prev(%v) = %q; want %q", tt.desc, tt.src, tt.t, got, tt.prev)
+		}
+	}
+
+	// a whole day of hourly fires on the fall back day - 25 hours
+	sc, _ := parseInterval("0 * * * *")
+	fires := 0
+	for ft := sc.Next(parse("2026-10-24 23:59")); ft.Before(parse("2026-10-26 00:00")); ft = sc.Next(ft) {
+		fires++
+	}
+	if fires != 24 {
+		t.Errorf("fall back day: %v hourly fires; want 24 - one per clock hour", fires)
+	}
+
+	// task location supersedes loc
+	tsk := TaskT{ExecutionInterval: "every day 09:00"}
+	tsk.location, _ = time.LoadLocation("America/New_York")
+	sc, err = taskInterval(tsk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sc.Next(parse("2026-07-17 12:00")).Format(layout); got != "2026-07-17 09:00 EDT" {
+		t.Errorf("task location: %v; want 2026-07-17 09:00 EDT", got)
+	}
+}
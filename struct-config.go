@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
 	"log"
 	"net/mail"
@@ -14,48 +13,76 @@ import (
 
 var operationMode string // test or prod
 
+// selection of a single task and wave - i.e. for preview
+var (
+	flagProject string
+	flagTask    string
+	flagWave    string // 2025-11
+	flagIDs     string // comma separated recipient IDs
+	flagOut     string // output directory
+	flagAddr    string // listen address of the preview server
+
+	flagInterval string // explain an interval - for schedule
+	flagN        int    // number of fire times - for schedule
+
+	dueListing bool // command due - listing only; no catch-up prompts or sending
+
+	unattended   bool   // no stdin - cron, systemd, daemon; see approval.go
+	flagApproval string // approval token
+)
+
 var startTime time.Time
 
 const stfmt = "2006-01-02T15:04" // start time format
 
 var loc *time.Location // init in load config
 
-func init() {
-
-	log.SetFlags(log.Lshortfile | log.Ltime)
+// configFile is read on startup - and reloaded by the daemon; flag -config
+var configFile = "config.json"
 
-	writeExampleConfig()
+// loadConfig reads and validates config.json into cfg;
+// on error, cfg remains unchanged
+func loadConfig() (errRet error) {
 
-	//
-	//
-	// read prod config
-	bts2, err := os.ReadFile("config.json")
+	bts2, err := os.ReadFile(configFile)
 	if err != nil {
-		log.Fatalf("could not read config\n\t%v", err)
+		return fmt.Errorf("could not read config\n\t%v", err)
 	}
 
-	err = json.Unmarshal(bts2, &cfg)
+	newCfg := configT{}
+	err = json.Unmarshal(bts2, &newCfg)
 	if err != nil {
-		log.Fatalf("could not unmarsch config\n\t%v", err)
+		return fmt.Errorf("could not unmarsch config\n\t%v", err)
 	}
 
-	// time zone
+	// validations and same_as operate on the global config;
+	// restored on error
+	prevCfg, prevLoc := cfg, loc
+	cfg = newCfg
+	defer func() {
+		if errRet != nil {
+			cfg, loc = prevCfg, prevLoc
+		}
+	}()
+
+	// time zone - the zone database is embedded; see timezones.go
+	if cfg.Location == "" {
+		return fmt.Errorf("config loc is missing - i.e. \"Europe/Berlin\"")
+	}
 	loc, err = time.LoadLocation(cfg.Location)
 	if err != nil {
-		log.Printf("configured location %v failed; using UTC_-2\n\t%v", cfg.Location, err)
-		// loc = time.FixedZone("UTC_-2", 2*60*60)
-		loc = time.FixedZone("UTC_+2", 2*60*60)
+		return fmt.Errorf("configured location %q: %w", cfg.Location, err)
 	}
 
 	// relay host integrity
 	if _, ok := cfg.RelayHorsts[cfg.DefaultHorst]; !ok {
-		log.Fatalf("cfg.DefaultHorst must be a key to RelayHorsts; %v", cfg.DefaultHorst)
+		return fmt.Errorf("cfg.DefaultHorst must be a key to RelayHorsts; %v", cfg.DefaultHorst)
 	}
 	for project, tasks := range cfg.Tasks {
 		for _, t := range tasks {
 			if t.RelayHost != "" {
 				if _, ok := cfg.RelayHorsts[cfg.DefaultHorst]; !ok {
-					log.Fatalf("project %v -  task %v - RelayHost %v does not exist", project, t.Name, t.RelayHost)
+					return fmt.Errorf("project %v -  task %v - RelayHost %v does not exist", project, t.Name, t.RelayHost)
 				}
 			}
 		}
@@ -64,16 +91,32 @@ func init() {
 	// consistency
 	for project, _ := range cfg.Tasks {
 		if _, ok := cfg.Projects[project]; !ok {
-			log.Fatalf("task %v has no project", project)
+			return fmt.Errorf("task %v has no project", project)
 		}
 	}
 
 	for project, _ := range cfg.Waves {
 		if _, ok := cfg.Projects[project]; !ok {
-			log.Fatalf("wave %v has no project", project)
+			return fmt.Errorf("wave %v has no project", project)
 		}
 	}
 
+	if err := checkSalutations(); err != nil {
+		return err
+	}
+	if err := checkRules(); err != nil {
+		return err
+	}
+	if err := checkIntervals(); err != nil {
+		return err
+	}
+	if err := checkCatchUp(); err != nil {
+		return err
+	}
+	if err := checkBlackouts(); err != nil {
+		return err
+	}
+
 	// same as
 	for project, tasks := range cfg.Tasks {
 		for idx1, t := range tasks {
@@ -111,12 +154,29 @@ func init() {
 							if orig.ExecutionInterval != "" {
 								t.ExecutionInterval = orig.ExecutionInterval
 							}
+							if orig.Wave != "" {
+								t.Wave = orig.Wave
+							}
+							if orig.Location != "" {
+								t.Location = orig.Location
+							}
+							if orig.LocalTime != "" {
+								t.LocalTime = orig.LocalTime
+							}
+							if orig.Blackout != "" {
+								t.Blackout = orig.Blackout
+							}
+							if orig.CatchUp != "" {
+								t.CatchUp = orig.CatchUp
+								t.CatchUpHours = orig.CatchUpHours
+							}
 							// this is the tricky setting - more info at t.SameAs
 							if orig.TemplateName != "" {
 								t.TemplateName = orig.TemplateName
 							}
 
 							t.SameAs = "" // prevent transitive copies
+							t.sameAsOf = orig.SameAs
 
 							cfg.Tasks[candProj][idx1] = t
 							break findSameAs
@@ -129,53 +189,11 @@ func init() {
 		}
 	}
 
-	// log.Print(util.IndentedDump(cfg))
-
-	//
-	//
-	// flags
-	// (requiring loc set above)
-	dsc1 := "mode must be 'test' or 'prod' \n\tgo-massmail -mode=test" // can be one or two leading hyphens
-	flg1 := flag.String(
-		"mode",            // -mode=xxx
-		"invalid-default", // default value
-		dsc1,
-	)
-
-	dsc2 := "parseable date and time - 2006-01-02T15:04" // can be one or two leading hyphens
-	flg2 := flag.String(
-		"start",                  // start=2006-01-02T15:04
-		time.Now().Format(stfmt), // default value
-		dsc2,
-	)
-
-	flag.Parse()
-
-	{
-		if *flg1 != "test" && *flg1 != "prod" {
-			log.Fatalf("mode must be 'test' or 'prod', was %q\n\tgo-massmail -mode=test", *flg1)
-		}
-		log.Printf("\tmode is %q\n", *flg1)
-		operationMode = *flg1
-	}
-
-	{
-		log.Printf("\tstart time %q\n", *flg2)
-		var err error
-		startTime, err = time.ParseInLocation(stfmt, *flg2, loc)
-		if err != nil {
-			log.Printf("\terror parsing start %q - %v", *flg2, err)
-			log.Fatalf("\tstart must be parseable '2006-01-02T15:04', was %q\n\tgo-massmail -start=%v", *flg2, time.Now().Format(stfmt))
-		}
-		dist := time.Until(startTime) // more succint, but less explicit than   startTime.Sub(time.Now())
-		if dist < -60*time.Second {
-			log.Fatalf("\tstart time %q is %d secs in the past", *flg2, dist/time.Second)
-		}
-		if dist > 24*time.Hour {
-			log.Fatalf("\tstart time cannot be more than 24 hours in the future; %q", *flg2)
-		}
+	if err := resolveLocations(); err != nil {
+		return err
 	}
 
+	return nil
 }
 
 type RelayHorst struct {
@@ -201,10 +219,12 @@ var credentials = map[string]map[string]string{
 	},
 }
 
-func (rh RelayHorst) getAuth() (auth smtp.Auth) {
+// getAuth takes the password from ENV or prompts for user and password;
+// unattended, a missing password is an error
+func (rh RelayHorst) getAuth() (smtp.Auth, error) {
 
 	if rh.Username == "" {
-		return nil
+		return nil, nil
 	}
 
 	pureHost := strings.Split(rh.HostNamePort, ":")[0]
@@ -218,31 +238,31 @@ func (rh RelayHorst) getAuth() (auth smtp.Auth) {
 
 	if pw == "" {
 
-		// xxxxx
+		if unattended {
+			return nil, fmt.Errorf("unattended - no password for %v; set ENV %v=secret", pureHost, env)
+		}
 
 		var errInp error
 		rh.Username, pw, errInp = userPass(
 			fmt.Sprintf("user for smtp - %v ", pureHost),
 		)
-
-		if errInp == nil {
-			credentials[pureHost] = map[string]string{}
-			credentials[pureHost]["user"] = rh.Username
-			credentials[pureHost]["password"] = pw
-		}
-
 		if errInp != nil {
-			log.Fatalf(`Set password for %v via ENV %v
+			return nil, fmt.Errorf(`no password for %v: %w
+				Set password via ENV %v
 				SET    %v=secret
 				export %v=secret
 				`,
-				pureHost, env,
+				pureHost, errInp,
+				env,
 				env,
 				env,
 			)
-
 		}
 
+		credentials[pureHost] = map[string]string{}
+		credentials[pureHost]["user"] = rh.Username
+		credentials[pureHost]["password"] = pw
+
 	}
 
 	if false {
@@ -252,14 +272,14 @@ func (rh RelayHorst) getAuth() (auth smtp.Auth) {
 			rh.Username,
 			pw,
 			pureHost,
-		)
+		), nil
 
 	}
 
 	return Auth2(
 		rh.Username,
 		pw,
-	)
+	), nil
 
 }
 
@@ -313,16 +333,50 @@ type TaskT struct {
 
 	HTML bool `json:"html,omitempty"` // is HTML or plain text
 
+	// move <style> rules into style attributes - for Outlook;
+	// see css-inline.go
+	InlineCSS bool `json:"inline_css,omitempty"`
+
 	// CSV file name has not setting - it is always project-taskname-lang.csv
 	URL *UrlT `json:"url,omitempty"` // 'wget' URL for recipients CSV
 
 	// a quick fix for sending reminders
 	UserIDSkip map[string]string `json:"user_id_skip,omitempty"`
 
+	// CSV columns, which must exist and must be non-empty for every recipient;
+	// checked in preflight; accessible in templates via {{.Field "company"}}
+	RequiredFields []string `json:"required_fields,omitempty"`
+
 	ExecutionTime     time.Time `json:"execution_time,omitempty"`     // when should the task be started - for cron jobs and parallel tasks
-	ExecutionInterval string    `json:"execution_interval,omitempty"` // similar to cron, supersedes Execution time
+	ExecutionInterval string    `json:"execution_interval,omitempty"` // cron expression or "every weekday 10:30"; supersedes Execution time; see schedule.go
+
+	// wave year and month - i.e. 2025-11; default is the wave containing the execution time; see waves.go
+	Wave string `json:"wave,omitempty"`
 
-	testmode bool `json:"-"`
+	// missed runs - skip, within catch_up_hours, always, confirm; see history.go
+	CatchUp      string `json:"catch_up,omitempty"`
+	CatchUpHours int    `json:"catch_up_hours,omitempty"`
+
+	// project blackout calendar - shift, block or ignore; default is the project action
+	Blackout string `json:"blackout,omitempty"`
+
+	// time zone for execution interval and local_time; default is cfg loc; see timezones.go
+	Location string `json:"loc,omitempty"`
+	// deliver at recipient local time - i.e. 09:00 - by recipient column tz or country
+	LocalTime string `json:"local_time,omitempty"`
+
+	// A/B variants of the template; see variants.go
+	Variants []VariantT `json:"variants,omitempty"`
+
+	// derived recipient fields - after global and project rules; see rules.go
+	Rules []RuleT `json:"rules,omitempty"`
+
+	testmode bool   `json:"-"`
+	explicit bool   `json:"-"` // run by name - see runExplicit
+	variant  string `json:"-"` // set by variantTask()
+	sameAsOf string `json:"-"` // original SameAs - for recurrence
+
+	location *time.Location `json:"-"` // resolved Location; nil means loc
 }
 
 // ProjectT is for data across all waves and tasks
@@ -339,10 +393,34 @@ type ProjectT struct {
 	Bounce string `json:"bounce,omitempty"`
 
 	TestRecipients []string `json:"test_recipients,omitempty"`
+
+	// template language, if no template exists for the recipient language
+	DefaultLanguage string `json:"default_language,omitempty"`
+
+	// supported recipient languages; empty means all
+	Languages []string `json:"languages,omitempty"`
+	// for recipients with unsupported language; first supported is chosen;
+	// defaults to DefaultLanguage
+	LanguageFallback []string `json:"language_fallback,omitempty"`
+
+	// salutation rules by language; built-in rules for de and en; see salutation.go
+	Salutations map[string]SalutationT `json:"salutations,omitempty"`
+
+	// derived recipient fields - after global rules; see rules.go
+	Rules []RuleT `json:"rules,omitempty"`
+
+	// abort, if closing dates are older than x days; default 15
+	ClosingDateMaxAge int `json:"closing_date_max_age_days,omitempty"`
+
+	// generated waves and execution times; see recurrence.go
+	Recurrence *RecurrenceT `json:"recurrence,omitempty"`
+
+	// holidays and date ranges without sending; see blackout.go
+	Blackout *BlackoutT `json:"blackout,omitempty"`
 }
 
 type configT struct {
-	Location       string                `json:"loc,omitempty"` // todo
+	Location       string                `json:"loc,omitempty"` // time zone - i.e. Europe/Berlin; required
 	AttachmentRoot string                `json:"attachment_root,omitempty"`
 	RelayHorsts    map[string]RelayHorst `json:"relay_horsts,omitempty"`
 	DefaultHorst   string                `json:"default_horst,omitempty"` // one of relayhorsts
@@ -357,6 +435,9 @@ type configT struct {
 	Projects map[string]ProjectT `json:"projects,omitempty"`
 	Waves    map[string][]WaveT  `json:"waves,omitempty"`
 	Tasks    map[string][]TaskT  `json:"tasks,omitempty"`
+
+	// derived recipient fields for all projects - i.e. by src_table; see rules.go
+	Rules []RuleT `json:"rules,omitempty"`
 }
 
 func writeExampleConfig() {
@@ -1,115 +1,399 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/term"
 )
 
-func keyboardInput(ret chan byte) {
+// From the menu after preflight until the task is done,
+// single keys control the run - no ENTER required:
+//
+//	c  continue now - in the menu
+//	p  pause / resume sending
+//	s  skip the current task - after the current message
+//	a  abort the run - after the current message
+//	i  progress and ETA
+//	v  toggle verbose logging
+//	h  keys
+//
+// The terminal is in raw mode meanwhile; CTRL+C restores it and exits at once.
+// Unattended or without a terminal, there is no key control.
 
-	fmt.Print("\tc - execute current task - now\n")
-	fmt.Print("\ts - skip to next task\n")
-	fmt.Print("\ta - abort (CTRL+C)\n")
-	fmt.Print("\t       press ENTER to submit\n")
+var errRunAborted = errors.New("run aborted by operator")
 
-	// probem with scanner - [Enter] is required to make it work. Probably the buffering of the stdin must be disabled for Linux and Windows distinctively
-	scanner := bufio.NewScanner(os.Stdin)
-	// scanner.Split(bufio.ScanBytes) // [character] and [Enter] are scanned separately, but [Enter] is still required
-	// scanner := bufio.ScanBytes()   // same behavior as with Split(bufio.ScanBytes)
-	// scanner.Buffer(make([]byte, 2), 2)  // only leads to overflow
+// quiet suppresses the per message log lines; toggled by key v
+var quiet atomic.Bool
 
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			log.Fatalf("error reading os.Stdin: %v", err)
-		}
-		// we get a line including [Enter]
-		txt := scanner.Text()
-		bts := scanner.Bytes()
-		var bt1 byte // extracting first byte, default is 0
-		if len(bts) > 0 {
-			bt1 = bts[0]
+func logVerbose(format string, args ...any) {
+	if !quiet.Load() {
+		log.Output(2, fmt.Sprintf(format, args...))
+	}
+}
+
+// stdin is read by a single goroutine - on demand, one byte per request;
+// once key control or a prompt stops waiting, stdin is not read any further.
+// A byte requested but not received is kept for the next caller.
+// Prompts after key control read via the same goroutine - see userStdin.
+var (
+	stdinOnce    sync.Once
+	stdinReq     chan struct{}
+	stdinCh      chan byte
+	stdinMu      sync.Mutex
+	stdinPending bool // requested, not yet received
+
+	stdinRaw atomic.Bool // terminal in raw mode - see makeRaw
+)
+
+var errInterrupted = errors.New("interrupted")
+var errStopped = errors.New("stopped")
+
+// readByte returns the next byte from stdin - or errStopped, once done is closed
+func readByte(done <-chan struct{}) (byte, error) {
+	stdinOnce.Do(func() {
+		stdinMu.Lock()
+		stdinReq = make(chan struct{}, 1)
+		stdinCh = make(chan byte)
+		stdinMu.Unlock()
+		go func() {
+			buf := make([]byte, 1)
+			for range stdinReq {
+				n, err := os.Stdin.Read(buf)
+				for n == 0 && err == nil {
+					n, err = os.Stdin.Read(buf)
+				}
+				if n > 0 {
+					stdinCh <- buf[0]
+				}
+				if err != nil {
+					close(stdinCh)
+					return
+				}
+			}
+		}()
+	})
+
+	stdinMu.Lock()
+	if !stdinPending {
+		stdinPending = true
+		stdinReq <- struct{}{}
+	}
+	stdinMu.Unlock()
+
+	select {
+	case b, ok := <-stdinCh:
+		if !ok {
+			return 0, io.EOF
 		}
-		outp := fmt.Sprintf("got text %v - byte %v - byte1 %v \n", txt, bts, bt1)
-		_ = outp
-
-		// a  -  bt1 ==  97   => abort
-		// c  -  bt1 ==  99   => continue
-		// s  -  bt1 == 115   => skip (next)
-		if bt1 == 97 {
-			log.Print("aborted")
-			ret <- 97
-			// os.Exit(0)
-			return
+		stdinMu.Lock()
+		stdinPending = false
+		stdinMu.Unlock()
+		return b, nil
+	case <-done:
+		return 0, errStopped
+	}
+}
+
+func stdinShared() bool {
+	stdinMu.Lock()
+	defer stdinMu.Unlock()
+	return stdinReq != nil
+}
+
+// makeRaw sets the terminal to raw mode; restore reverts it
+func makeRaw(fd int) (restore func(), err error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	prev := stdinRaw.Swap(true) // password prompt during key control
+	return func() {
+		term.Restore(fd, state)
+		stdinRaw.Store(prev)
+	}, nil
+}
+
+// readLine reads up to ENTER from stdin;
+// in raw mode, the terminal does neither echo nor handle backspace
+func readLine() (string, error) {
+	line := []byte{}
+	for {
+		b, err := readByte(nil)
+		if err != nil {
+			if len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
 		}
-		if bt1 == 99 {
-			// break continueSending
-			// return 99
-			log.Print("continue current task now")
-			ret <- 99
-			return
+		raw := stdinRaw.Load()
+		switch {
+		case b == '\n':
+			return string(bytes.TrimRight(line, "\r")), nil
+		case b == '\r' && raw:
+			return string(line), nil
+		case b == 3 && raw:
+			return "", errInterrupted
+		case (b == 127 || b == 8) && raw:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		default:
+			line = append(line, b)
 		}
-		if bt1 == 115 {
-			log.Print("next task")
-			// return 115
-			ret <- 115
-			return
+	}
+}
+
+// crlfWriter - in raw mode, newline does not return the carriage
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (cw crlfWriter) Write(p []byte) (int, error) {
+	_, err := cw.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n")))
+	return len(p), err
+}
+
+// controlT is the state of the running task - changed by keys
+type controlT struct {
+	mu     sync.Mutex
+	paused bool
+	cont   bool // continue now - menu only
+	skip   bool
+	abort  bool
+	wake   chan struct{}
+
+	label   string
+	total   int
+	sent    int
+	sending time.Duration // without waits and pauses - for the ETA
+}
+
+// ctl is nil without key control
+var ctl *controlT
+
+func (c *controlT) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func keyHelp() {
+	log.Print("\tkeys: c continue  p pause/resume  s skip task  a abort run  i progress  v verbose  h help")
+}
+
+// startKeys sets the terminal to raw mode and handles keys for the task;
+// stop restores the terminal
+func startKeys(label string) (stop func()) {
+
+	fd := int(os.Stdin.Fd())
+	if unattended || !term.IsTerminal(fd) {
+		return func() {}
+	}
+	restoreTerm, err := makeRaw(fd)
+	if err != nil {
+		log.Printf("no key control: %v", err)
+		return func() {}
+	}
+	log.SetOutput(crlfWriter{os.Stderr})
+
+	c := &controlT{label: label, wake: make(chan struct{}, 1)}
+	ctl = c
+	done := make(chan struct{})
+	restore := func() {
+		restoreTerm()
+		log.SetOutput(os.Stderr)
+	}
+
+	go func() {
+		for {
+			b, err := readByte(done)
+			if err != nil {
+				return
+			}
+			c.mu.Lock()
+			switch b {
+			case 3: // CTRL+C
+				c.mu.Unlock()
+				restore()
+				log.Print("interrupted")
+				os.Exit(exitFail)
+			case 'c':
+				c.cont = true
+			case 'p', ' ':
+				c.paused = !c.paused
+				if c.paused {
+					log.Print("paused; p to resume")
+				} else {
+					log.Print("resumed")
+				}
+			case 's':
+				c.skip = true
+				log.Printf("skipping %v - after the current message", c.label)
+			case 'a':
+				c.abort = true
+				log.Print("aborting the run - after the current message")
+			case 'i':
+				log.Print(c.progress())
+			case 'v':
+				if quiet.Load() {
+					quiet.Store(false)
+					log.Print("verbose logging on")
+				} else {
+					quiet.Store(true)
+					log.Print("verbose logging off - i for progress")
+				}
+			case 'h', '?':
+				keyHelp()
+			}
+			c.mu.Unlock()
+			c.signal()
 		}
-		// else - any other byte code ignored
+	}()
 
-		//
-		// try again...
+	return func() {
+		close(done)
+		ctl = nil
+		restore()
 	}
+}
 
+// progress - locked by caller
+func (c *controlT) progress() string {
+	if c.total == 0 {
+		return fmt.Sprintf("%v: not sending yet", c.label)
+	}
+	s := fmt.Sprintf("%v: %v of %v sent", c.label, c.sent, c.total)
+	if c.sent > 0 && c.sent < c.total {
+		eta := c.sending / time.Duration(c.sent) * time.Duration(c.total-c.sent)
+		s += fmt.Sprintf(" - ETA %v at %v", strings.TrimSpace(formatDuration(eta)), time.Now().Add(eta).In(loc).Format("15:04:05"))
+	}
+	if c.paused {
+		s += " - paused"
+	}
+	return s
 }
 
-// loopSync cannot be combined with keyboard inut
-func loopSync(waitSeconds int) {
-	fmt.Printf("\tcontinue in %v secs\n", waitSeconds)
-	for i := 0; i < waitSeconds*5; i++ {
-		fmt.Print(".")
-		time.Sleep(time.Second / 5)
+// state returns errTaskSkipped or errRunAborted, if requested
+func (c *controlT) state() (paused, cont bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.abort:
+		return c.paused, c.cont, errRunAborted
+	case c.skip:
+		return c.paused, c.cont, errTaskSkipped
 	}
-	fmt.Print("\n")
+	return c.paused, c.cont, nil
 }
 
-// loopAsync combines waiting with keyboard input.
-// Return values are
-//
-//	'a' =  97   => abort
-//	'c' =  99   => continue
-//	's' = 115   => skip (next)
-func loopAsync(waitSeconds int) byte {
-
-	// channel 1
-	keyInput := make(chan byte)
-	go keyboardInput(keyInput)
-
-	// channel 2
-	deadline := time.Now().Add(time.Duration(waitSeconds * int(time.Second)))
-	ticker := time.NewTicker(2 * time.Second) // make it slow - so the printing of "." does not conflict with human input
-
-	//
-	// combining channel 1 and 2
-	fmt.Printf("\tcontinue in %v secs\n", waitSeconds)
+// sendingStart announces the number of messages
+func sendingStart(total int) {
+	if c := ctl; c != nil {
+		c.mu.Lock()
+		c.total = total
+		c.mu.Unlock()
+	}
+}
+
+// sentOne counts a message and its duration
+func sentOne(d time.Duration) {
+	if c := ctl; c != nil {
+		c.mu.Lock()
+		c.sent++
+		c.sending += d
+		c.mu.Unlock()
+	}
+}
+
+// checkpoint is called before each message; it blocks while paused
+func checkpoint() error {
+	c := ctl
+	if c == nil {
+		return nil
+	}
+	for {
+		paused, _, err := c.state()
+		if err != nil || !paused {
+			return err
+		}
+		<-c.wake
+	}
+}
+
+// menuWait waits after preflight - or until key c;
+// the countdown stops while paused
+func menuWait(waitSeconds int) error {
+	c := ctl
+	if c == nil {
+		log.Print("no key control - continuing")
+		return nil
+	}
+	keyHelp()
+	log.Printf("\tcontinue in %v secs", waitSeconds)
+	remaining := time.Duration(waitSeconds) * time.Second
+	const tick = 2 * time.Second // slow - so the dots do not conflict with the key help
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
 	for {
+		paused, cont, err := c.state()
+		if err != nil || cont {
+			fmt.Fprint(os.Stderr, "\r\n")
+			return err
+		}
 		select {
-		case tick := <-ticker.C:
-			fmt.Print(".")
-			if tick.After(deadline) {
-				ticker.Stop()
-				// close(ticker.C)
-				fmt.Print("\n")
-				// break mark1
-				return 99
+		case <-ticker.C:
+			if paused {
+				continue
+			}
+			fmt.Fprint(os.Stderr, ".")
+			remaining -= tick
+			if remaining <= 0 {
+				fmt.Fprint(os.Stderr, "\r\n")
+				return nil
 			}
-		case key := <-keyInput:
-			ticker.Stop()
-			fmt.Print("\n")
-			return key
+		case <-c.wake:
 		}
 	}
+}
 
+// waitUntil logs the remaining time every few seconds
+// and returns at the precise time t - or early by key s or a
+func waitUntil(t time.Time) error {
+	const interval = 5
+	ticker := time.NewTicker(interval * time.Second)
+	defer ticker.Stop()
+	var wake chan struct{}
+	if c := ctl; c != nil {
+		wake = c.wake
+	}
+	strT := t.Format(stfmt)
+	log.Printf("%5s  until %s", formatDuration(time.Until(t)), strT)
+	for {
+		if c := ctl; c != nil {
+			if _, _, err := c.state(); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ticker.C:
+			dist := time.Until(t)
+			log.Printf("%5s  until %s", formatDuration(dist), strT)
+			if dist <= interval*time.Second {
+				log.Printf("   %5.2f secs until precise start time", float64(dist.Round(time.Second))/float64(time.Second))
+				time.Sleep(dist)
+				return nil
+			}
+		case <-wake:
+		}
+	}
 }
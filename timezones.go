@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// zone database compiled in - Windows hosts have none
+	_ "time/tzdata"
+)
+
+// Tasks may have their own time zone "loc" - for execution intervals and local_time.
+// With "local_time": "09:00", prod emails are delivered at 09:00 recipient local time;
+// the zone is taken from recipient CSV column tz - i.e. America/New_York -
+// or from column country - i.e. US.
+// Recipients are sent in batches - at the next 09:00 after the start time
+// in their zone; thus a run may last up to 24 hours.
+// Recipients without zone are sent at local_time in the task zone.
+
+// countryZones maps ISO 3166 country codes to a representative zone;
+// for countries spanning several zones, the capital or the economic center is chosen
+var countryZones = map[string]string{
+	"AR": "America/Argentina/Buenos_Aires",
+	"AT": "Europe/Vienna",
+	"AU": "Australia/Sydney",
+	"BE": "Europe/Brussels",
+	"BG": "Europe/Sofia",
+	"BR": "America/Sao_Paulo",
+	"CA": "America/Toronto",
+	"CH": "Europe/Zurich",
+	"CL": "America/Santiago",
+	"CN": "Asia/Shanghai",
+	"CY": "Asia/Nicosia",
+	"CZ": "Europe/Prague",
+	"DE": "Europe/Berlin",
+	"DK": "Europe/Copenhagen",
+	"EE": "Europe/Tallinn",
+	"ES": "Europe/Madrid",
+	"FI": "Europe/Helsinki",
+	"FR": "Europe/Paris",
+	"GB": "Europe/London",
+	"GR": "Europe/Athens",
+	"HK": "Asia/Hong_Kong",
+	"HR": "Europe/Zagreb",
+	"HU": "Europe/Budapest",
+	"IE": "Europe/Dublin",
+	"IL": "Asia/Jerusalem",
+	"IN": "Asia/Kolkata",
+	"IS": "Atlantic/Reykjavik",
+	"IT": "Europe/Rome",
+	"JP": "Asia/Tokyo",
+	"KR": "Asia/Seoul",
+	"LT": "Europe/Vilnius",
+	"LU": "Europe/Luxembourg",
+	"LV": "Europe/Riga",
+	"MT": "Europe/Malta",
+	"MX": "America/Mexico_City",
+	"NL": "Europe/Amsterdam",
+	"NO": "Europe/Oslo",
+	"NZ": "Pacific/Auckland",
+	"PL": "Europe/Warsaw",
+	"PT": "Europe/Lisbon",
+	"RO": "Europe/Bucharest",
+	"RS": "Europe/Belgrade",
+	"SE": "Europe/Stockholm",
+	"SG": "Asia/Singapore",
+	"SI": "Europe/Ljubljana",
+	"SK": "Europe/Bratislava",
+	"TR": "Europe/Istanbul",
+	"TW": "Asia/Taipei",
+	"UA": "Europe/Kyiv",
+	"UK": "Europe/London", // not ISO, but common
+	"US": "America/New_York",
+	"ZA": "Africa/Johannesburg",
+}
+
+// resolveLocations validates task zones and local times;
+// called after same_as expansion
+func resolveLocations() error {
+	for project, tsks := range cfg.Tasks {
+		for idx, tsk := range tsks {
+			if tsk.Location != "" {
+				tl, err := time.LoadLocation(tsk.Location)
+				if err != nil {
+					return fmt.Errorf("%v-%v: loc %q: %w", project, tsk.Name, tsk.Location, err)
+				}
+				cfg.Tasks[project][idx].location = tl
+			}
+			if tsk.LocalTime != "" {
+				if _, err := time.Parse("15:04", tsk.LocalTime); err != nil {
+					return fmt.Errorf("%v-%v: local_time %q - want 09:00", project, tsk.Name, tsk.LocalTime)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// taskLocation - task loc supersedes cfg loc
+func taskLocation(tsk TaskT) *time.Location {
+	if tsk.location != nil {
+		return tsk.location
+	}
+	return loc
+}
+
+var zoneCache = map[string]*time.Location{}
+var zoneMtx sync.Mutex
+
+// loadZone caches time.LoadLocation - called for every recipient
+func loadZone(name string) (*time.Location, error) {
+	zoneMtx.Lock()
+	defer zoneMtx.Unlock()
+	if zl, ok := zoneCache[name]; ok {
+		return zl, nil
+	}
+	zl, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	zoneCache[name] = zl
+	return zl, nil
+}
+
+// recipientLocation from CSV column tz or country;
+// nil, if neither is given; error for unknown values
+func recipientLocation(rec *Recipient) (*time.Location, error) {
+	if tz := strings.TrimSpace(rec.Fields["tz"]); tz != "" {
+		rl, err := loadZone(tz)
+		if err != nil {
+			return nil, fmt.Errorf("tz %q", tz)
+		}
+		return rl, nil
+	}
+	if cc := strings.ToUpper(strings.TrimSpace(rec.Fields["country"])); cc != "" {
+		zone, ok := countryZones[cc]
+		if !ok {
+			return nil, fmt.Errorf("country %q", cc)
+		}
+		rl, err := loadZone(zone)
+		if err != nil {
+			return nil, fmt.Errorf("country %q - zone %q", cc, zone)
+		}
+		return rl, nil
+	}
+	return nil, nil
+}
+
+// batchT are recipients with the same delivery time
+type batchT struct {
+	At   time.Time
+	Recs []*Recipient
+}
+
+// localBatches groups recipients by delivery time -
+// the next local_time after start in their zone;
+// a single batch at start without local_time;
+// unresolved lists recipients with unknown zone - they get the task zone
+func localBatches(tsk TaskT, recs []*Recipient, start time.Time) (batches []batchT, unresolved []string) {
+
+	if tsk.LocalTime == "" {
+		return []batchT{{At: start, Recs: recs}}, nil
+	}
+	clock, _ := time.Parse("15:04", tsk.LocalTime) // validated in resolveLocations
+
+	byTime := map[time.Time][]*Recipient{}
+	for _, rec := range recs {
+		rl, err := recipientLocation(rec)
+		if err != nil {
+			unresolved = append(unresolved, fmt.Sprintf("%v - %v", rec.Email, err))
+		}
+		if rl == nil {
+			rl = taskLocation(tsk)
+		}
+		s := start.In(rl)
+		at := time.Date(s.Year(), s.Month(), s.Day(), clock.Hour(), clock.Minute(), 0, 0, rl)
+		if at.Before(s) {
+			at = at.AddDate(0, 0, 1)
+		}
+		at = at.In(loc)
+		byTime[at] = append(byTime[at], rec)
+	}
+
+	for at, rs := range byTime {
+		batches = append(batches, batchT{At: at, Recs: rs})
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].At.Before(batches[j].At)
+	})
+	return batches, unresolved
+}
+
+// logLocalBatches - in preflight
+func logLocalBatches(tsk TaskT, recs []*Recipient) {
+	if tsk.LocalTime == "" {
+		return
+	}
+	batches, unresolved := localBatches(tsk, recs, startTime)
+	log.Printf("  delivery at %v recipient local time - %v batch(es)", tsk.LocalTime, len(batches))
+	for _, b := range batches {
+		log.Printf("    %v   %4d recipient(s)", b.At.Format("Mon 2006-01-02 15:04 MST"), len(b.Recs))
+	}
+	for idx, u := range unresolved {
+		if idx == 20 {
+			log.Printf("    ... %v more", len(unresolved)-idx)
+			break
+		}
+		log.Printf("    unknown zone; using %v: %v", taskLocation(tsk), u)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// tplSharedDir contains institute-wide layouts, legal footers and signatures;
+// a file of the same name in tpl/[project] takes precedence.
+var tplSharedDir = filepath.Join(".", "tpl", "shared")
+
+// {{template "partial-invite-footer-de.md" .}}  {{block "layout-de.html" .}}
+var tplRefRe = regexp.MustCompile(`{{-?\s*(?:template|block)\s+"([^"]+)"`)
+
+// templatePath looks up a template file in tpl/[project], then in tpl/shared
+func templatePath(project, name string) (string, bool) {
+	for _, dir := range []string{filepath.Join(".", "tpl", project), tplSharedDir} {
+		pth := filepath.Join(dir, name)
+		if fi, err := os.Stat(pth); err == nil && !fi.IsDir() {
+			return pth, true
+		}
+	}
+	return "", false
+}
+
+// templateIncludes returns the files of all templates
+// referenced by {{template}} or {{block}} - transitively, in order of discovery.
+// References without file - i.e. {{block "content" .}} - are defined inline.
+func templateIncludes(project, name string) ([]string, error) {
+
+	includes := []string{}
+	seen := map[string]bool{name: true}
+
+	queue := []string{name}
+	for len(queue) > 0 {
+		pth, ok := templatePath(project, queue[0])
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		bts, err := os.ReadFile(pth)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range tplRefRe.FindAllStringSubmatch(string(bts), -1) {
+			if seen[m[1]] {
+				continue
+			}
+			seen[m[1]] = true
+			if incPth, ok := templatePath(project, m[1]); ok {
+				includes = append(includes, incPth)
+				queue = append(queue, m[1])
+			}
+		}
+	}
+
+	return includes, nil
+}
+
+// parseTemplate parses a main template and its includes.
+// Includes are parsed first - deepest first - the main template last;
+// thus {{define}} in an including template overrides {{block}} defaults
+// of included layouts.
+func parseTemplate(project, name string, funcs map[string]any) (*template.Template, error) {
+
+	pth, ok := templatePath(project, name)
+	if !ok {
+		return nil, fmt.Errorf("template %v neither in tpl/%v nor in %v", name, project, tplSharedDir)
+	}
+
+	includes, err := templateIncludes(project, name)
+	if err != nil {
+		return nil, err
+	}
+
+	t := template.New(name).Funcs(funcs)
+	for i := len(includes) - 1; i >= 0; i-- {
+		bts, err := os.ReadFile(includes[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(filepath.Base(includes[i])).Parse(string(bts)); err != nil {
+			return nil, fmt.Errorf("could not parse template %v\n\t%w", includes[i], err)
+		}
+	}
+
+	bts, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.Parse(string(bts)); err != nil {
+		return nil, fmt.Errorf("could not parse main template %v\n\t%w", pth, err)
+	}
+
+	return t, nil
+}
+
+var tplVariantLogged = map[string]bool{}
+var tplVariantMtx sync.Mutex
+
+// resolveTemplate returns the template file name for a task, wave and language;
+// candidates in order
+//
+//	invitation-de-2025-10.md   wave specific
+//	invitation-de.md
+//	invitation-en-2025-10.md   project default language
+//	invitation-en.md
+//
+// The chosen variant is logged once.
+func resolveTemplate(project string, wv WaveT, tsk TaskT, lang string) (string, error) {
+
+	templateFile := tsk.Name
+	// check for explicitly different email template
+	if tsk.TemplateName != "" {
+		templateFile = tsk.TemplateName
+	}
+	ext := "md"
+	if tsk.HTML {
+		ext = "html"
+	}
+
+	langs := []string{lang}
+	if dl := cfg.Projects[project].DefaultLanguage; dl != "" && dl != lang {
+		langs = append(langs, dl)
+	}
+
+	candidates := []string{}
+	for _, lng := range langs {
+		candidates = append(candidates,
+			fmt.Sprintf("%v-%v-%d-%02d.%v", templateFile, lng, wv.Year, wv.Month, ext),
+			fmt.Sprintf("%v-%v.%v", templateFile, lng, ext),
+		)
+	}
+
+	for _, fn := range candidates {
+		if _, ok := templatePath(project, fn); ok {
+			key := fmt.Sprintf("%v-%v-%v-%d-%02d-%v", project, tsk.Name, tsk.variant, wv.Year, wv.Month, lang)
+			tplVariantMtx.Lock()
+			if !tplVariantLogged[key] {
+				tplVariantLogged[key] = true
+				log.Printf("  template for %v %v: %v", tsk.Name, lang, fn)
+			}
+			tplVariantMtx.Unlock()
+			return fn, nil
+		}
+	}
+
+	return "", fmt.Errorf("no template for %v - %v; tried %v", project, tsk.Name, strings.Join(candidates, ", "))
+}
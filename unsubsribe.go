@@ -1,7 +1,8 @@
 package main
 
 import (
-	"encoding/json"
+	"encoding/csv"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -24,12 +25,19 @@ func restore(s string) string {
 	return s
 }
 
-// fill unsubscribers
-func init() {
+// suppressFile contains unsubscribers added locally by command suppress;
+// same columns as the download
+var suppressFile = filepath.Join(".", "csv", "unsubscribe", "suppress.csv")
+
+// loadUnsubscribers downloads unsubscription requests
+// and merges the local suppressions
+func loadUnsubscribers() {
 
 	dir := filepath.Join(".", "csv", "unsubscribe")
 	os.MkdirAll(dir, os.ModePerm)
 
+	unsubscribers = map[string]map[string]map[string]bool{}
+
 	wave := WaveT{}
 	wave.Year = 1000
 	wave.Month = 10
@@ -44,7 +52,14 @@ func init() {
 	flat, err := getCSV("unsubscribe", wave, task, false)
 	if err != nil {
 		log.Print(err)
-		return
+	}
+
+	if fileExists(suppressFile) {
+		local, err := getCSV("unsubscribe", wave, TaskT{Name: "suppress"}, false)
+		if err != nil {
+			log.Print(err)
+		}
+		flat = append(flat, local...)
 	}
 
 	for _, us := range flat {
@@ -66,8 +81,33 @@ func init() {
 	for k := range unsubscribers {
 		log.Printf(" project %v has %v unsubsribers", k, len(unsubscribers[k]))
 	}
+}
+
+// appendSuppression adds an email to suppressFile
+func appendSuppression(project, task, email string) error {
+
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return fmt.Errorf("suppress: %q is no email address", email)
+	}
 
-	// temporarily dump
-	dbg, _ := json.MarshalIndent(unsubscribers, "", "\t")
-	log.Print(string(dbg))
+	os.MkdirAll(filepath.Dir(suppressFile), os.ModePerm)
+	writeHeader := !fileExists(suppressFile)
+	f, err := os.OpenFile(suppressFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("suppress: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Comma = ';'
+	if writeHeader {
+		w.Write([]string{"project", "task", "email"})
+	}
+	w.Write([]string{project, task, email})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("suppress: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VariantT is an A/B variant of a task template - i.e. a different subject line.
+// Recipients are assigned deterministically by hash of their ID.
+type VariantT struct {
+	Name         string `json:"name,omitempty"`
+	TemplateName string `json:"template_name,omitempty"` // default is [template name]-[variant name]
+	Weight       int    `json:"weight,omitempty"`        // default 1
+}
+
+// assignVariant returns the variant name for a recipient - or empty string;
+// the same ID always gets the same variant, also across tasks with identical variants
+func assignVariant(rec Recipient, tsk TaskT) string {
+
+	if len(tsk.Variants) == 0 {
+		return ""
+	}
+
+	total := 0
+	for _, v := range tsk.Variants {
+		total += max(v.Weight, 1)
+	}
+
+	key := rec.ID
+	if key == "" {
+		key = strings.ToLower(rec.Email)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	n := int(h.Sum32() % uint32(total))
+
+	for _, v := range tsk.Variants {
+		n -= max(v.Weight, 1)
+		if n < 0 {
+			return v.Name
+		}
+	}
+	return tsk.Variants[len(tsk.Variants)-1].Name
+}
+
+// variantTask returns the task with the template name of a variant
+func variantTask(tsk TaskT, variant string) TaskT {
+	if variant == "" {
+		return tsk
+	}
+	for _, v := range tsk.Variants {
+		if v.Name != variant {
+			continue
+		}
+		templateFile := tsk.Name
+		if tsk.TemplateName != "" {
+			templateFile = tsk.TemplateName
+		}
+		tsk.TemplateName = templateFile + "-" + v.Name
+		if v.TemplateName != "" {
+			tsk.TemplateName = v.TemplateName
+		}
+		tsk.Variants = nil
+		tsk.variant = v.Name
+		return tsk
+	}
+	return tsk
+}
+
+// variantTasks returns one task per variant - for lint
+func variantTasks(tsk TaskT) []TaskT {
+	if len(tsk.Variants) == 0 {
+		return []TaskT{tsk}
+	}
+	tsks := []TaskT{}
+	for _, v := range tsk.Variants {
+		tsks = append(tsks, variantTask(tsk, v.Name))
+	}
+	return tsks
+}
+
+// logVariants shows the split - in preflight
+func logVariants(tsk TaskT, recs []*Recipient) {
+	if len(tsk.Variants) == 0 {
+		return
+	}
+	cnts := map[string]int{}
+	for _, rec := range recs {
+		if !strings.Contains(rec.NoMail, "noMail") {
+			cnts[rec.Variant]++
+		}
+	}
+	names := make([]string, 0, len(cnts))
+	for name := range cnts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("  variant %-12v %4d recipient(s)", name, cnts[name])
+	}
+}
+
+// appendSendLog records a sent email - for joining survey responses by variant;
+// csv/[project]/[task]-[yyyy]-[mm]-sent.csv
+func appendSendLog(project string, wv WaveT, tsk TaskT, rec Recipient) error {
+
+	fn := filepath.Join(".", "csv", project, fmt.Sprintf("%v-%d-%02d-sent.csv", tsk.Name, wv.Year, wv.Month))
+	writeHeader := !fileExists(fn)
+
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("send log: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Comma = ';'
+	if writeHeader {
+		w.Write([]string{"time", "id", "email", "lang", "variant", "smtp"})
+	}
+	w.Write([]string{
+		time.Now().In(loc).Format(time.RFC3339),
+		rec.ID,
+		rec.Email,
+		rec.Language,
+		rec.Variant,
+		rec.SMTP,
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("send log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// waveKey - 2025-11
+func waveKey(wv WaveT) string {
+	return fmt.Sprintf("%d-%02d", wv.Year, wv.Month)
+}
+
+// waveStart is the first day of the wave month
+func waveStart(wv WaveT) time.Time {
+	return time.Date(wv.Year, wv.Month, 1, 0, 0, 0, 0, loc)
+}
+
+// waveByKey returns the wave for key 2025-11;
+// the last wave for empty key
+func waveByKey(project, key string) (WaveT, error) {
+	wvs := cfg.Waves[project]
+	if len(wvs) < 1 {
+		return WaveT{}, fmt.Errorf("project %v has no waves", project)
+	}
+	if key == "" {
+		return wvs[len(wvs)-1], nil
+	}
+	for _, wv := range wvs {
+		if waveKey(wv) == key {
+			return wv, nil
+		}
+	}
+	return WaveT{}, fmt.Errorf("project %v has no wave %v", project, key)
+}
+
+// waveByDate returns the wave whose period contains t;
+// a period lasts from the wave month until the next wave month;
+// false, if t precedes all waves
+func waveByDate(project string, t time.Time) (WaveT, bool) {
+	wvs := append([]WaveT{}, cfg.Waves[project]...)
+	sort.SliceStable(wvs, func(i, j int) bool {
+		return waveStart(wvs[i]).Before(waveStart(wvs[j]))
+	})
+	for i := len(wvs) - 1; i >= 0; i-- {
+		if !t.Before(waveStart(wvs[i])) {
+			return wvs[i], true
+		}
+	}
+	return WaveT{}, false
+}
+
+// taskTime is the execution time of a task - or the start time for intervals
+func taskTime(tsk TaskT) time.Time {
+	if tsk.ExecutionTime.IsZero() {
+		return startTime
+	}
+	return tsk.ExecutionTime
+}
+
+// selectWave chooses the wave for a task; precedence:
+// key - i.e. from flag -wave, task wave reference, by execution time, last wave.
+// Returns how the wave was chosen.
+func selectWave(project, key string, tsk TaskT, t time.Time) (WaveT, string, error) {
+	if key != "" {
+		wv, err := waveByKey(project, key)
+		return wv, "flag", err
+	}
+	if tsk.Wave != "" {
+		wv, err := waveByKey(project, tsk.Wave)
+		if err != nil {
+			return wv, "task", fmt.Errorf("task %v: %w", tsk.Name, err)
+		}
+		return wv, "task", nil
+	}
+	if wv, ok := waveByDate(project, t); ok {
+		return wv, "date", nil
+	}
+	wv, err := waveByKey(project, "")
+	return wv, "last", err
+}
+
+// checkWave validates the closing dates of a wave against execution time t
+func checkWave(project string, wv WaveT, t time.Time) error {
+
+	prelimi := wv.ClosingDatePreliminary
+	lastDue := wv.ClosingDateLastDue
+	if !prelimi.IsZero() && !lastDue.IsZero() && prelimi.After(lastDue) {
+		return fmt.Errorf("wave %v: preliminary closing date %v after last due %v",
+			waveKey(wv), prelimi.Format("2006-01-02"), lastDue.Format("2006-01-02"))
+	}
+
+	if ahead := waveStart(wv).AddDate(0, -1, 0); t.Before(ahead) {
+		return fmt.Errorf("wave %v: execution time %v is more than a month ahead of the wave",
+			waveKey(wv), t.Format("2006-01-02 15:04"))
+	}
+
+	maxAge := cfg.Projects[project].ClosingDateMaxAge
+	if maxAge == 0 {
+		maxAge = 15
+	}
+	for _, cd := range []time.Time{prelimi, lastDue} {
+		if !cd.IsZero() && t.After(cd.AddDate(0, 0, maxAge)) {
+			return fmt.Errorf("wave %v: execution time %v is more than %v days after closing date %v",
+				waveKey(wv), t.Format("2006-01-02 15:04"), maxAge, cd.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+// taskWave selects and validates the wave of a task
+func taskWave(project, key string, tsk TaskT) (WaveT, error) {
+	t := taskTime(tsk)
+	wv, how, err := selectWave(project, key, tsk, t)
+	if err != nil {
+		return wv, err
+	}
+	if how == "last" {
+		log.Printf("\t%v-%-22v - no wave for %v - using last wave %v", project, tsk.Name, t.Format("2006-01-02"), waveKey(wv))
+	}
+	return wv, checkWave(project, wv, t)
+}